@@ -0,0 +1,83 @@
+package control
+
+import (
+	"context"
+	"iter"
+)
+
+// AppsAPI manages Ably apps.
+type AppsAPI interface {
+	Apps(ctx context.Context) ([]App, error)
+	AllApps(ctx context.Context) iter.Seq2[App, error]
+	App(ctx context.Context, id string) (App, error)
+	FindAppByName(ctx context.Context, name string) (App, error)
+	CreateApp(ctx context.Context, app *NewApp) (App, error)
+	UpdateApp(ctx context.Context, id string, app *AppUpdate) (App, error)
+	DeleteApp(ctx context.Context, id string) error
+}
+
+// KeysAPI manages the API keys of Ably apps.
+type KeysAPI interface {
+	Keys(ctx context.Context, appID string) ([]Key, error)
+	AllKeys(ctx context.Context, appID string) iter.Seq2[Key, error]
+	Key(ctx context.Context, appID, keyID string) (Key, error)
+	FindKeyByName(ctx context.Context, appID, name string) (Key, error)
+	CreateKey(ctx context.Context, appID string, key *NewKey) (Key, error)
+	UpdateKey(ctx context.Context, appID, keyID string, key *KeyUpdate) (Key, error)
+	RevokeKey(ctx context.Context, appID, keyID string) error
+}
+
+// NamespacesAPI manages the namespaces of Ably apps.
+type NamespacesAPI interface {
+	Namespaces(ctx context.Context, appID string) ([]Namespace, error)
+	AllNamespaces(ctx context.Context, appID string) iter.Seq2[Namespace, error]
+	Namespace(ctx context.Context, appID, namespaceID string) (Namespace, error)
+	CreateNamespace(ctx context.Context, appID string, namespace *Namespace) (Namespace, error)
+	UpdateNamespace(ctx context.Context, appID, namespaceID string, namespace *NamespaceUpdate) (Namespace, error)
+	DeleteNamespace(ctx context.Context, appID, namespaceID string) error
+}
+
+// QueuesAPI manages the queues of Ably apps.
+type QueuesAPI interface {
+	Queues(ctx context.Context, appID string) ([]Queue, error)
+	AllQueues(ctx context.Context, appID string) iter.Seq2[Queue, error]
+	Queue(ctx context.Context, appID, queueID string) (Queue, error)
+	FindQueueByName(ctx context.Context, appID, name string) (Queue, error)
+	CreateQueue(ctx context.Context, appID string, queue *NewQueue) (Queue, error)
+	DeleteQueue(ctx context.Context, appID, queueID string) error
+}
+
+// RulesAPI manages the integration rules of Ably apps.
+type RulesAPI interface {
+	Rules(ctx context.Context, appID string) ([]Rule, error)
+	AllRules(ctx context.Context, appID string) iter.Seq2[Rule, error]
+	Rule(ctx context.Context, appID, ruleID string) (Rule, error)
+	CreateRule(ctx context.Context, appID string, rule *NewRule) (Rule, error)
+	UpdateRule(ctx context.Context, appID, ruleID string, rule *RuleUpdate) (Rule, error)
+	DeleteRule(ctx context.Context, appID, ruleID string) error
+}
+
+// IngressRulesAPI manages the ingress rules of Ably apps.
+type IngressRulesAPI interface {
+	IngressRules(ctx context.Context, appID string) ([]IngressRule, error)
+	AllIngressRules(ctx context.Context, appID string) iter.Seq2[IngressRule, error]
+	IngressRule(ctx context.Context, appID, ruleID string) (IngressRule, error)
+	CreateIngressRule(ctx context.Context, appID string, rule *NewIngressRule) (IngressRule, error)
+	UpdateIngressRule(ctx context.Context, appID, ruleID string, rule *NewIngressRule) (IngressRule, error)
+	DeleteIngressRule(ctx context.Context, appID, ruleID string) error
+}
+
+// ControlAPI covers every operation of the Control API. It is implemented
+// by *Client, and can be implemented by fakes such as controlmock.Fake, or
+// by decorators which wrap another ControlAPI.
+type ControlAPI interface {
+	Me(ctx context.Context) (Me, error)
+	AppsAPI
+	KeysAPI
+	NamespacesAPI
+	QueuesAPI
+	RulesAPI
+	IngressRulesAPI
+}
+
+var _ ControlAPI = (*Client)(nil)
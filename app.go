@@ -1,5 +1,10 @@
 package control
 
+import (
+	"context"
+	"iter"
+)
+
 // A struct representing the settable fields of an Ably application.
 type NewApp struct {
 	// The application ID.
@@ -60,29 +65,107 @@ type App struct {
 	ApnsUseSandboxEndpoint bool `json:"apnsUseSandboxEndpoint"`
 }
 
+// AppUpdate is used to update an Ably app. Only the fields which are not
+// nil are changed.
+type AppUpdate struct {
+	// The application name.
+	Name *string `json:"name,omitempty"`
+	// The application status. Disabled applications will not accept
+	// new connections and will return an error to all clients.
+	Status *string `json:"status,omitempty"`
+	// Enforce TLS for all connections. This setting overrides any channel setting.
+	TLSOnly *bool `json:"tlsOnly,omitempty"`
+	// The Firebase Cloud Messaging key.
+	FcmKey *string `json:"fcmKey,omitempty"`
+	// The Firebase Service Account key. To use the service account key you must also provide a projectId.
+	FcmServiceAccount *string `json:"fcmServiceAccount,omitempty"`
+	// The Firebase Project ID. To authenticate with firebase you must also provide a service account key.
+	FcmProjectId *string `json:"fcmProjectId,omitempty"`
+	// The Apple Push Notification service certificate.
+	ApnsCertificate *string `json:"apnsCertificate,omitempty"`
+	// The Apple Push Notification service private key.
+	ApnsPrivateKey *string `json:"apnsPrivateKey,omitempty"`
+	// Use the Apple Push Notification service sandbox endpoint.
+	ApnsUseSandboxEndpoint *bool `json:"apnsUseSandboxEndpoint,omitempty"`
+}
+
 // Apps fetches a list of all your Ably apps.
-func (c *Client) Apps() ([]App, error) {
-	var apps []App
-	err := c.request("GET", "/accounts/"+c.accountID+"/apps", nil, &apps)
-	return apps, err
+func (c *Client) Apps(ctx context.Context) ([]App, error) {
+	return collect(c.AllApps(ctx))
+}
+
+// AllApps returns an iterator over all your Ably apps, which fetches further
+// pages as it is consumed.
+func (c *Client) AllApps(ctx context.Context) iter.Seq2[App, error] {
+	return func(yield func(App, error) bool) {
+		accountID, err := c.resolveAccountID(ctx)
+		if err != nil {
+			yield(App{}, err)
+			return
+		}
+		for app, err := range list[App](ctx, c, "Apps", "/accounts/"+accountID+"/apps") {
+			if !yield(app, err) {
+				return
+			}
+		}
+	}
+}
+
+// App fetches the Ably app with the specified ID. An error matching
+// ErrNotFound is returned if there is no such app.
+func (c *Client) App(ctx context.Context, id string) (App, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return App{}, err
+	}
+	return findByID(c.AllApps(ctx), "app", "/accounts/"+accountID+"/apps", id, func(a App) string { return a.ID })
+}
+
+// FindAppByName fetches the Ably app with the specified name. An error
+// matching ErrNotFound is returned if there is no such app, or one matching
+// ErrAmbiguous if more than one app has the name.
+func (c *Client) FindAppByName(ctx context.Context, name string) (App, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return App{}, err
+	}
+	return findByName(c.AllApps(ctx), "app", "/accounts/"+accountID+"/apps", name,
+		func(a App) string { return a.Name },
+		func(a App) string { return a.ID },
+	)
 }
 
 // CreateApp creates a new Ably app.
-func (c *Client) CreateApp(app *NewApp) (App, error) {
+//
+// If the call is retried, a new app with the same name is assumed to have
+// been created by an earlier attempt. Apps have no creation time, so when
+// retries are enabled the apps which already have the name are listed
+// before the first attempt, so that they are not mistaken for it. If they
+// can't be listed, retries rely on the idempotency key alone.
+func (c *Client) CreateApp(ctx context.Context, app *NewApp) (App, error) {
 	var out App
-	err := c.request("POST", "/accounts/"+c.accountID+"/apps", app, &out)
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return out, err
+	}
+	find := findNew(ctx, c, c.AllApps,
+		func(a App) bool { return a.Name == app.Name },
+		func(a App) string { return a.ID },
+	)
+	err = c.create(ctx, "CreateApp", "/accounts/"+accountID+"/apps", app, &out, find)
 	return out, err
 }
 
-// UpdateApp updates an existing Ably app.
-func (c *Client) UpdateApp(id string, app *NewApp) (App, error) {
+// UpdateApp updates an existing Ably app. Only the fields of app which are
+// set are changed.
+func (c *Client) UpdateApp(ctx context.Context, id string, app *AppUpdate) (App, error) {
 	var out App
-	err := c.request("PATCH", "/apps/"+id, app, &out)
+	err := c.request(ctx, "UpdateApp", "PATCH", "/apps/"+id, app, &out)
 	return out, err
 }
 
 // DeleteApp deletes an Ably app.
-func (c *Client) DeleteApp(id string) error {
-	err := c.request("DELETE", "/apps/"+id, nil, nil)
+func (c *Client) DeleteApp(ctx context.Context, id string) error {
+	err := c.request(ctx, "DeleteApp", "DELETE", "/apps/"+id, nil, nil)
 	return err
 }
@@ -1,34 +1,71 @@
 package control
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestApp(t *testing.T) {
+	ctx := context.Background()
 	client, _ := newTestClient(t)
 	app := newTestApp(t, &client)
 
-	apps, err := client.Apps()
+	apps, err := client.Apps(ctx)
 	assert.NoError(t, err)
 
 	assert.NotEqual(t, len(apps), 0)
 
-	a, err := client.UpdateApp(app.ID, &NewApp{TLSOnly: false})
+	a, err := client.UpdateApp(ctx, app.ID, &AppUpdate{TLSOnly: Bool(false)})
 	assert.NoError(t, err)
 	assert.False(t, a.TLSOnly)
-	newApp := NewApp{
-		Status:                 "disabled",
-		TLSOnly:                true,
-		ApnsUseSandboxEndpoint: true,
+	assert.Equal(t, app.Name, a.Name)
+	update := AppUpdate{
+		Status:                 String("disabled"),
+		TLSOnly:                Bool(true),
+		ApnsUseSandboxEndpoint: Bool(true),
 	}
-	a, err = client.UpdateApp(app.ID, &newApp)
+	a, err = client.UpdateApp(ctx, app.ID, &update)
 	assert.NoError(t, err)
 
-	assert.Equal(t, newApp.Status, a.Status)
-	assert.Equal(t, newApp.TLSOnly, a.TLSOnly)
-	assert.Equal(t, newApp.ApnsUseSandboxEndpoint, a.ApnsUseSandboxEndpoint)
+	assert.Equal(t, "disabled", a.Status)
+	assert.True(t, a.TLSOnly)
+	assert.True(t, a.ApnsUseSandboxEndpoint)
+	assert.Equal(t, app.Name, a.Name)
 
-	err = client.DeleteApp(app.ID)
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
 }
+
+func TestAppLookup(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	a, err := client.App(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, app, a)
+
+	a, err = client.FindAppByName(ctx, app.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, app, a)
+
+	_, err = client.App(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = client.FindAppByName(ctx, app.Name+"-missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestAppUpdateMarshal(t *testing.T) {
+	b, err := json.Marshal(&AppUpdate{TLSOnly: Bool(false)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"tlsOnly":false}`, string(b))
+
+	b, err = json.Marshal(&AppUpdate{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(b))
+}
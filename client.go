@@ -19,10 +19,14 @@ package control
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // The URL of the Ably Control API.
@@ -35,86 +39,319 @@ const defaultAblyAgent = "ably-control-go/" + VERSION
 
 // Client represents a REST client for the Ably Control API.
 type Client struct {
-	token     string
-	accountID string
+	// tokenSource supplies the access token for each request.
+	tokenSource TokenSource
+	// account holds the account ID, and is shared by copies of the Client.
+	account *account
 	// Url is the base url for the REST API.
 	Url string
 
 	/// ablyAgent is the value to set as the Ably-Agent HTTP header.
 	ablyAgent string
+
+	// httpClient is used to make requests, http.DefaultClient is used if nil.
+	httpClient *http.Client
+	// header contains extra headers which are sent with every request.
+	header http.Header
+	// timeout is applied to each request if non-zero.
+	timeout time.Duration
+	// retry controls how failed requests are retried.
+	retry RetryPolicy
+	// limiter limits the rate of requests if non-nil.
+	limiter *rateLimiter
+	// middleware wraps every call, the first being the outermost.
+	middleware []Middleware
+	// logger logs every request if non-nil.
+	logger    *slog.Logger
+	logLevels LogLevels
+	// metrics records every call if non-nil.
+	metrics Metrics
 }
 
 // NewClient creates a new REST client.
 //
 // Creating a new client involves making a request to the REST API to
-// fetch the account ID accociated with the token.
-func NewClient(token string) (Client, Me, error) {
-	return NewClientWithURL(token, API_URL)
-}
-
-// / NewClientWithURL is the same as NewClient but also takes a custom url.
-func NewClientWithURL(token, url string) (Client, Me, error) {
-	client := Client{
-		token:     token,
-		Url:       url,
-		ablyAgent: defaultAblyAgent,
+// fetch the account ID accociated with the token. The request is bound
+// to ctx.
+func NewClient(ctx context.Context, token string, opts ...Option) (Client, Me, error) {
+	client, err := newClient(token, opts)
+	if err != nil {
+		return client, Me{}, err
 	}
-	me, err := client.Me()
+	me, err := client.Me(ctx)
 	if err != nil {
 		return client, me, err
 	}
-	client.accountID = me.Account.ID
+	client.account.id = me.Account.ID
 	return client, me, nil
 }
 
+// NewClientWithURL is the same as NewClient but also takes a custom url.
+func NewClientWithURL(ctx context.Context, token, url string, opts ...Option) (Client, Me, error) {
+	return NewClient(ctx, token, append([]Option{WithURL(url)}, opts...)...)
+}
+
+// NewClientWithAccountID creates a new REST client for the account with the
+// given ID without making any requests.
+//
+// If accountID is empty it is fetched from the REST API by the first call
+// which needs it, such as Apps or CreateApp.
+func NewClientWithAccountID(token, accountID string, opts ...Option) (Client, error) {
+	client, err := newClient(token, opts)
+	if err != nil {
+		return client, err
+	}
+	client.account.id = accountID
+	return client, nil
+}
+
+// account holds the ID of the account a Client manages.
+type account struct {
+	// fetch is held while the ID is fetched, so that concurrent calls only
+	// fetch it once.
+	fetch sync.Mutex
+
+	mu sync.Mutex
+	id string
+}
+
+// AccountID returns the ID of the account the client manages, or an empty
+// string if it has not been fetched yet.
+func (c *Client) AccountID() string {
+	if c.account == nil {
+		return ""
+	}
+	c.account.mu.Lock()
+	defer c.account.mu.Unlock()
+	return c.account.id
+}
+
+// resolveAccountID returns the ID of the account the client manages,
+// fetching it from the REST API if it is not known yet.
+func (c *Client) resolveAccountID(ctx context.Context) (string, error) {
+	if c.account == nil {
+		c.account = &account{}
+	}
+	c.account.fetch.Lock()
+	defer c.account.fetch.Unlock()
+	if id := c.AccountID(); id != "" {
+		return id, nil
+	}
+	me, err := c.Me(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.account.mu.Lock()
+	defer c.account.mu.Unlock()
+	c.account.id = me.Account.ID
+	return c.account.id, nil
+}
+
 // AppendAblyAgent appends an extra entry to the value sent as the Ably-Agent
 // HTTP header.
 func (c *Client) AppendAblyAgent(product, version string) {
 	c.ablyAgent = fmt.Sprintf("%s %s/%s", c.ablyAgent, product, version)
 }
 
-func (c *Client) request(method, path string, in, out interface{}) error {
-	var inR io.Reader
-	if in != nil {
-		inData, err := json.Marshal(in)
-		if err != nil {
-			return err
-		}
-		inR = bytes.NewReader(inData)
+// request performs a single Control API call. The call is aborted if ctx is
+// cancelled or its deadline expires before the response has been read.
+func (c *Client) request(ctx context.Context, operation, method, path string, in, out interface{}) error {
+	return c.exec(ctx, &Request{Operation: operation, Method: method, Path: path, Body: in}, out)
+}
+
+// exec performs a call described by req, decoding the response into out if
+// it is not nil.
+func (c *Client) exec(ctx context.Context, req *Request, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
 	}
-	req, err := http.NewRequest(method, c.Url+path, inR)
+	res, err := c.call(ctx, req)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Ably-Agent", c.ablyAgent)
-	if in != nil {
-		req.Header.Set("Content-Type", "application/json")
+	defer res.Body.Close()
+	if out != nil {
+		return json.NewDecoder(res.Body).Decode(out)
 	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+	return nil
+}
+
+// call passes a request through the Client's middleware, and then sends it
+// to the REST API.
+func (c *Client) call(ctx context.Context, req *Request) (*http.Response, error) {
+	req.AccountID = c.AccountID()
+	var doer Doer = DoerFunc(c.doRequest)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		doer = c.middleware[i](doer)
 	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		body, _ := io.ReadAll(res.Body)
-		var errorInfo ErrorInfo
-		err = json.Unmarshal(body, &errorInfo)
-		if err == nil {
-			errorInfo.APIPath = path
-			return errorInfo
-		} else {
-			return ErrorInfo{
-				Message:    string(body),
-				Code:       0,
-				StatusCode: res.StatusCode,
-				HRef:       "",
-				APIPath:    path,
+	return doer.Do(ctx, req)
+}
+
+// doRequest encodes the body of a request and sends it to the REST API.
+func (c *Client) doRequest(ctx context.Context, req *Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = json.Marshal(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res, err := c.do(ctx, req, body)
+	if errorInfo, ok := err.(ErrorInfo); ok {
+		errorInfo.request = req.Body
+		return nil, errorInfo
+	}
+	return res, err
+}
+
+// do sends a request, retrying it as allowed by the Client's retry policy,
+// and returns the first successful response. Unsuccessful responses are
+// returned as an ErrorInfo.
+func (c *Client) do(ctx context.Context, req *Request, body []byte) (res *http.Response, err error) {
+	callStart := time.Now()
+	attempt := 0
+	defer func() {
+		statusCode := 0
+		if res != nil {
+			statusCode = res.StatusCode
+		}
+		c.observe(ctx, req, callStart, attempt, statusCode, err)
+	}()
+
+	refreshed := false
+	for attempt = 1; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		res, err := c.send(ctx, req, token, body)
+		if err != nil {
+			err = &TransportError{Method: req.Method, APIPath: req.Path, Err: err}
+			c.logAttempt(ctx, req, body, attempt, start, nil, err)
+			// the request may have been applied, so it is only repeated
+			// if it has an idempotency key.
+			if attempt >= c.retry.MaxAttempts || !c.retry.retryableCreate(req) || ctx.Err() != nil {
+				return nil, err
+			}
+			if res, ok := c.findCreated(ctx, req); ok {
+				return res, nil
+			}
+			if err := sleep(ctx, c.retry.delay(attempt, nil)); err != nil {
+				return nil, err
 			}
+			continue
+		}
+		if c.limiter != nil {
+			c.limiter.update(res)
+		}
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			c.logAttempt(ctx, req, body, attempt, start, res, nil)
+			return res, nil
+		}
+		errorInfo := readErrorInfo(res, req.Path)
+		errorInfo.Attempts = attempt
+		c.logAttempt(ctx, req, body, attempt, start, res, errorInfo)
+		if res.StatusCode == http.StatusUnauthorized && !refreshed {
+			// repeat the request once if the token source has a new token.
+			refreshed = true
+			if refresher, ok := c.tokenSource.(TokenRefresher); ok {
+				if newToken, err := refresher.Refresh(ctx); err == nil && newToken != token {
+					continue
+				}
+			}
+		}
+		if attempt >= c.retry.MaxAttempts || !c.retry.retryable(req, errorInfo) {
+			return nil, errorInfo
+		}
+		if res, ok := c.findCreated(ctx, req); ok {
+			return res, nil
+		}
+		if err := sleep(ctx, c.retry.delay(attempt, res.Header)); err != nil {
+			return nil, err
 		}
 	}
-	if out != nil {
-		return json.NewDecoder(res.Body).Decode(out)
+}
+
+// findCreated looks for a resource created by an earlier attempt at a
+// create call, and returns it as a response if found.
+func (c *Client) findCreated(ctx context.Context, req *Request) (*http.Response, bool) {
+	if req.find == nil {
+		return nil, false
+	}
+	v, ok, err := req.find(ctx)
+	if err != nil || !ok {
+		return nil, false
+	}
+	res, err := found(v)
+	return res, err == nil
+}
+
+// token returns the access token to use for the next request.
+func (c *Client) token(ctx context.Context) (string, error) {
+	if c.tokenSource == nil {
+		return "", nil
+	}
+	return c.tokenSource.Token(ctx)
+}
+
+// send makes a single HTTP request to the REST API.
+func (c *Client) send(ctx context.Context, req *Request, token string, body []byte) (*http.Response, error) {
+	var inR io.Reader
+	if body != nil {
+		inR = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, c.Url+req.Path, inR)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		httpReq.Header[k] = v
+	}
+	for k, v := range req.Header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Ably-Agent", c.ablyAgent)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(httpReq)
+}
+
+// readErrorInfo reads the ErrorInfo from an unsuccessful response and
+// closes its body.
+func readErrorInfo(res *http.Response, path string) ErrorInfo {
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	var errorInfo ErrorInfo
+	err := json.Unmarshal(body, &errorInfo)
+	if err == nil {
+		errorInfo.APIPath = path
+		if errorInfo.StatusCode == 0 {
+			errorInfo.StatusCode = res.StatusCode
+		}
+		return errorInfo
+	} else {
+		return ErrorInfo{
+			Message:    string(body),
+			Code:       0,
+			StatusCode: res.StatusCode,
+			HRef:       "",
+			APIPath:    path,
+			notJSON:    true,
+		}
 	}
-	return nil
 }
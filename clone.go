@@ -0,0 +1,373 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ably/ably-control-go/internal/redact"
+)
+
+// CloneOptions controls what CloneApp copies.
+type CloneOptions struct {
+	// SkipSecrets leaves out the rules and ingress rules whose targets
+	// contain secrets, such as AWS credentials, a Kafka password or a
+	// database URL with a password, so that the copy does not use them.
+	// Such rules are not copied at all, not even without their secrets;
+	// they are listed in CloneResult.NotCopied, and must be created in the
+	// copy by the caller.
+	SkipSecrets bool
+	// SkipPushCredentials leaves out the app's FCM key, service account and
+	// project ID. The APNs certificate and private key are never copied, as
+	// the Control API does not return them.
+	SkipPushCredentials bool
+}
+
+// CloneResult describes the copy of an app made by CloneApp.
+type CloneResult struct {
+	// The new app.
+	App App
+	// IDs maps the IDs of the source app and of its keys, queues, rules and
+	// ingress rules to the IDs of their copies. Namespaces keep their IDs.
+	IDs map[string]string
+	// NotCopied lists the items of the source app which were not copied.
+	NotCopied []NotCopied
+}
+
+// NotCopied is an item of an app which CloneApp did not copy.
+type NotCopied struct {
+	// The kind of item, such as "key" or "rule".
+	Resource string
+	// The ID of the item in the source app.
+	ID string
+	// Why the item was not copied.
+	Reason string
+	// The error returned when creating the copy, if any.
+	Err error
+}
+
+func (r *CloneResult) notCopied(resource, id, reason string, err error) {
+	r.NotCopied = append(r.NotCopied, NotCopied{Resource: resource, ID: id, Reason: reason, Err: err})
+}
+
+// CloneApp creates a new app named newName with the same settings as the app
+// with the specified ID, and copies its namespaces, keys, queues, rules and
+// ingress rules to it.
+//
+// The copies of keys have the same names and capabilities but new secrets.
+// References to queues and signing keys in rule targets are changed to point
+// at the copies. Revoked keys are not copied.
+//
+// An error is returned if the source app can't be read or the new app can't
+// be created. Items which fail to be copied after that are listed in the
+// result's NotCopied field, and the rest are still copied.
+//
+// If ctx carries an idempotency key, see ContextWithIdempotencyKey, each
+// create call uses a key derived from it.
+func (c *Client) CloneApp(ctx context.Context, srcAppID, newName string, opts CloneOptions) (CloneResult, error) {
+	result := CloneResult{IDs: map[string]string{}}
+
+	// read everything first, so that a failure does not leave a partial copy.
+	src, err := c.App(ctx, srcAppID)
+	if err != nil {
+		return result, err
+	}
+	namespaces, err := c.Namespaces(ctx, srcAppID)
+	if err != nil {
+		return result, err
+	}
+	keys, err := c.Keys(ctx, srcAppID)
+	if err != nil {
+		return result, err
+	}
+	queues, err := c.Queues(ctx, srcAppID)
+	if err != nil {
+		return result, err
+	}
+	rules, ingressRules, err := c.appRules(ctx, srcAppID)
+	if err != nil {
+		return result, err
+	}
+
+	newApp := NewApp{
+		Name:                   newName,
+		Status:                 src.Status,
+		TLSOnly:                src.TLSOnly,
+		ApnsUseSandboxEndpoint: src.ApnsUseSandboxEndpoint,
+	}
+	if !opts.SkipPushCredentials {
+		newApp.FcmKey = src.FcmKey
+		newApp.FcmServiceAccount = src.FcmServiceAccount
+		newApp.FcmProjectId = src.FcmProjectId
+	}
+	app, err := c.CreateApp(deriveIdempotencyKey(ctx, "app"), &newApp)
+	if err != nil {
+		return result, err
+	}
+	result.App = app
+	result.IDs[src.ID] = app.ID
+
+	for _, namespace := range namespaces {
+		if _, err := c.CreateNamespace(deriveIdempotencyKey(ctx, "namespace/"+namespace.ID), app.ID, &namespace); err != nil {
+			result.notCopied("namespace", namespace.ID, "create failed", err)
+		}
+	}
+	var keyConfigs []KeyConfig
+	for _, key := range keys {
+		if key.Status != 0 {
+			result.notCopied("key", key.ID, "the key is revoked", nil)
+			continue
+		}
+		keyConfigs = append(keyConfigs, KeyConfig{
+			Ref:             key.ID,
+			Name:            key.Name,
+			Capability:      key.Capability,
+			RevocableTokens: key.RevocableTokens,
+		})
+	}
+	ids, errs, err := c.createKeys(ctx, app.ID, keyConfigs)
+	if err != nil {
+		return result, err
+	}
+	for i, key := range keyConfigs {
+		if errs[i] != nil {
+			result.notCopied("key", key.Ref, "create failed", errs[i])
+			continue
+		}
+		result.IDs[key.Ref] = ids[i]
+	}
+	queueConfigs := make([]QueueConfig, len(queues))
+	for i, queue := range queues {
+		queueConfigs[i] = QueueConfig{
+			Ref:        queue.ID,
+			Name:       queue.Name,
+			Region:     queue.Region,
+			Ttl:        queue.Ttl,
+			MaxLength:  queue.MaxLength,
+			DeadLetter: queue.DeadLetter,
+		}
+	}
+	ids, errs, err = c.createQueues(ctx, app.ID, queueConfigs)
+	if err != nil {
+		return result, err
+	}
+	for i, queue := range queueConfigs {
+		if errs[i] != nil {
+			result.notCopied("queue", queue.Ref, "create failed", errs[i])
+			continue
+		}
+		result.IDs[queue.Ref] = ids[i]
+	}
+	for _, rule := range rules {
+		if opts.SkipSecrets && hasSecrets(rule.Target) {
+			result.notCopied("rule", rule.ID, "the target contains secrets", nil)
+			continue
+		}
+		target, err := remapTarget(rule.Target, result.IDs, result.IDs)
+		if err != nil {
+			result.notCopied("rule", rule.ID, err.Error(), nil)
+			continue
+		}
+		copied, err := c.CreateRule(deriveIdempotencyKey(ctx, "rule/"+rule.ID), app.ID, &NewRule{
+			Status:      rule.Status,
+			RequestMode: rule.RequestMode,
+			Source:      rule.Source,
+			Target:      target,
+		})
+		if err != nil {
+			result.notCopied("rule", rule.ID, "create failed", err)
+			continue
+		}
+		result.IDs[rule.ID] = copied.ID
+	}
+	for _, rule := range ingressRules {
+		if opts.SkipSecrets && hasSecrets(rule.Target) {
+			result.notCopied("ingress rule", rule.ID, "the target contains secrets", nil)
+			continue
+		}
+		copied, err := c.CreateIngressRule(deriveIdempotencyKey(ctx, "ingress-rule/"+rule.ID), app.ID, &NewIngressRule{Status: rule.Status, Target: rule.Target})
+		if err != nil {
+			result.notCopied("ingress rule", rule.ID, "create failed", err)
+			continue
+		}
+		result.IDs[rule.ID] = copied.ID
+	}
+	return result, nil
+}
+
+// createKeys creates keys for the app with the specified ID, and returns
+// the ID of each key or the error creating it. A key with the same name as
+// one the app already has, such as the root key the Control API may create
+// along with an app, updates that key instead.
+func (c *Client) createKeys(ctx context.Context, appID string, keys []KeyConfig) ([]string, []error, error) {
+	existing, err := c.Keys(ctx, appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := map[string]Key{}
+	for _, key := range existing {
+		if key.Status == 0 {
+			byName[key.Name] = key
+		}
+	}
+	ids := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		var created Key
+		if match, ok := byName[key.Name]; ok {
+			delete(byName, key.Name)
+			created, errs[i] = c.UpdateKey(ctx, appID, match.ID, &KeyUpdate{
+				Capability:      key.Capability,
+				RevocableTokens: Bool(key.RevocableTokens),
+			})
+		} else {
+			created, errs[i] = c.CreateKey(deriveIdempotencyKey(ctx, "key/"+key.Ref), appID, &NewKey{
+				Name:            key.Name,
+				Capability:      key.Capability,
+				RevocableTokens: key.RevocableTokens,
+			})
+		}
+		ids[i] = created.ID
+	}
+	return ids, errs, nil
+}
+
+// createQueues creates queues for the app with the specified ID, and
+// returns the ID of each queue or the error creating it. Dead letter queues
+// are created by the Control API along with other queues, so they are
+// matched to those of the app by name and region instead.
+func (c *Client) createQueues(ctx context.Context, appID string, queues []QueueConfig) ([]string, []error, error) {
+	ids := make([]string, len(queues))
+	errs := make([]error, len(queues))
+	deadLetter := false
+	for i, queue := range queues {
+		if queue.DeadLetter {
+			deadLetter = true
+			continue
+		}
+		created, err := c.CreateQueue(deriveIdempotencyKey(ctx, "queue/"+queue.Ref), appID, &NewQueue{
+			Name:      queue.Name,
+			Ttl:       queue.Ttl,
+			MaxLength: queue.MaxLength,
+			Region:    queue.Region,
+		})
+		ids[i], errs[i] = created.ID, err
+	}
+	if !deadLetter {
+		return ids, errs, nil
+	}
+	created, err := c.Queues(ctx, appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, queue := range queues {
+		if !queue.DeadLetter {
+			continue
+		}
+		if q, ok := findDeadLetter(created, queue.Name, queue.Region); ok {
+			ids[i] = q.ID
+		} else {
+			errs[i] = fmt.Errorf("control: dead letter queue %s was not created", queue.Name)
+		}
+	}
+	return ids, errs, nil
+}
+
+// findDeadLetter returns the dead letter queue in queues with the given name
+// and region.
+func findDeadLetter(queues []Queue, name string, region Region) (Queue, bool) {
+	for _, q := range queues {
+		if q.DeadLetter && q.Name == name && q.Region == region {
+			return q, true
+		}
+	}
+	return Queue{}, false
+}
+
+// targetReference returns the kind, "queue" or "key", and the ID of the
+// queue or signing key which target refers to, if any.
+func targetReference(target Target) (string, string) {
+	switch t := target.(type) {
+	case *AmqpTarget:
+		return "queue", t.QueueID
+	case *HttpGoogleCloudFunctionTarget:
+		return "key", t.SigningKeyID
+	case *HttpAzureFunctionTarget:
+		return "key", t.SigningKeyID
+	case *HttpCloudfareWorkerTarget:
+		return "key", t.SigningKeyID
+	case *HttpZapierTarget:
+		return "key", t.SigningKeyID
+	case *HttpTarget:
+		return "key", t.SigningKeyID
+	}
+	return "", ""
+}
+
+// remapTarget returns a copy of target whose queue ID is replaced by its
+// value in queues, or whose signing key ID is replaced by its value in keys.
+// An error is returned if the target refers to a queue or key with no value.
+func remapTarget(target Target, queues, keys map[string]string) (Target, error) {
+	remap := func(resource, id string) (string, error) {
+		ids := keys
+		if resource == "queue" {
+			ids = queues
+		}
+		if id == "" {
+			return "", nil
+		}
+		if newID, ok := ids[id]; ok {
+			return newID, nil
+		}
+		return "", fmt.Errorf("the %s %s was not copied", resource, id)
+	}
+	var err error
+	switch t := target.(type) {
+	case *AmqpTarget:
+		copied := *t
+		copied.QueueID, err = remap("queue", t.QueueID)
+		return &copied, err
+	case *HttpGoogleCloudFunctionTarget:
+		copied := *t
+		copied.SigningKeyID, err = remap("key", t.SigningKeyID)
+		return &copied, err
+	case *HttpAzureFunctionTarget:
+		copied := *t
+		copied.SigningKeyID, err = remap("key", t.SigningKeyID)
+		return &copied, err
+	case *HttpCloudfareWorkerTarget:
+		copied := *t
+		copied.SigningKeyID, err = remap("key", t.SigningKeyID)
+		return &copied, err
+	case *HttpZapierTarget:
+		copied := *t
+		copied.SigningKeyID, err = remap("key", t.SigningKeyID)
+		return &copied, err
+	case *HttpTarget:
+		copied := *t
+		copied.SigningKeyID, err = remap("key", t.SigningKeyID)
+		return &copied, err
+	}
+	return target, nil
+}
+
+// hasSecrets reports whether the JSON encoding of v contains any values
+// which would be redacted from logs, such as passwords.
+func hasSecrets(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	// normalise the encoding, so that it only differs from the redacted one
+	// if something was redacted.
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return false
+	}
+	data, err = json.Marshal(decoded)
+	if err != nil {
+		return false
+	}
+	return !bytes.Equal(data, redact.JSON(data))
+}
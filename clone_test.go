@@ -0,0 +1,140 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneApp(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	f := newTestFixture(t, &client)
+
+	name := "clone-" + fmt.Sprint(rand.Uint64())
+	result, err := client.CloneApp(ctx, f.App.ID, name, CloneOptions{SkipSecrets: true})
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.DeleteApp(ctx, result.App.ID) })
+	assert.Equal(t, name, result.App.Name)
+	assert.Equal(t, result.App.ID, result.IDs[f.App.ID])
+	assert.ElementsMatch(t, []NotCopied{
+		{Resource: "key", ID: f.RevokedKey.ID, Reason: "the key is revoked"},
+		{Resource: "rule", ID: f.StaleRule.ID, Reason: "the key " + f.RevokedKey.ID + " was not copied"},
+		{Resource: "rule", ID: f.KafkaRule.ID, Reason: "the target contains secrets"},
+		{Resource: "ingress rule", ID: f.MongoRule.ID, Reason: "the target contains secrets"},
+	}, result.NotCopied)
+
+	namespace, err := client.Namespace(ctx, result.App.ID, "chat")
+	assert.NoError(t, err)
+	assert.True(t, namespace.Persisted)
+
+	newKey, err := client.Key(ctx, result.App.ID, result.IDs[f.Key.ID])
+	assert.NoError(t, err)
+	assert.Equal(t, f.Key.Name, newKey.Name)
+	assert.Equal(t, f.Key.Capability, newKey.Capability)
+	assert.NotEqual(t, f.Key.Key, newKey.Key)
+
+	newQueue, err := client.Queue(ctx, result.App.ID, result.IDs[f.Queue.ID])
+	assert.NoError(t, err)
+	assert.Equal(t, f.Queue.Name, newQueue.Name)
+	assert.Equal(t, f.Queue.Region, newQueue.Region)
+
+	rule, err := client.Rule(ctx, result.App.ID, result.IDs[f.AmqpRule.ID])
+	assert.NoError(t, err)
+	assert.Equal(t, newQueue.ID, rule.Target.(*AmqpTarget).QueueID)
+	rule, err = client.Rule(ctx, result.App.ID, result.IDs[f.WebhookRule.ID])
+	assert.NoError(t, err)
+	assert.Equal(t, newKey.ID, rule.Target.(*HttpTarget).SigningKeyID)
+	// the copy of the dead letter queue is the one created along with the
+	// copy of the queue.
+	assert.Equal(t, result.App.ID+":eu-west-1-a:deadletter", result.IDs[f.DeadLetter.ID])
+	rule, err = client.Rule(ctx, result.App.ID, result.IDs[f.DeadLetterRule.ID])
+	assert.NoError(t, err)
+	assert.Equal(t, result.IDs[f.DeadLetter.ID], rule.Target.(*AmqpTarget).QueueID)
+
+	withSecrets, err := client.CloneApp(ctx, f.App.ID, name+"-secrets", CloneOptions{})
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.DeleteApp(ctx, withSecrets.App.ID) })
+	assert.Contains(t, withSecrets.IDs, f.KafkaRule.ID)
+	assert.Contains(t, withSecrets.IDs, f.MongoRule.ID)
+	assert.Len(t, withSecrets.NotCopied, 2)
+
+	rules, ingressRules, err := client.appRules(ctx, withSecrets.App.ID)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 4)
+	assert.Len(t, ingressRules, 1)
+}
+
+func TestCloneAppNotFound(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+
+	_, err := client.CloneApp(ctx, "missing", "copy", CloneOptions{})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCreateQueuesDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	// there is only a dead letter queue in regions which have other queues.
+	ids, errs, err := client.createQueues(ctx, app.ID, []QueueConfig{
+		{Ref: "events", Name: "events", Region: UsEast1A, Ttl: 60, MaxLength: 100},
+		{Ref: "deadletter", Name: "deadletter", Region: UsEast1A, DeadLetter: true},
+		{Ref: "deadletter-eu", Name: "deadletter", Region: EuWest1A, DeadLetter: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{app.ID + ":us-east-1-a:events", app.ID + ":us-east-1-a:deadletter", ""}, ids)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.EqualError(t, errs[2], "control: dead letter queue deadletter was not created")
+}
+
+// newKeyRecordingClient returns a client which records the idempotency keys
+// of the requests it makes.
+func newKeyRecordingClient(t *testing.T) (Client, *[]string) {
+	var mu sync.Mutex
+	var keys []string
+	recorder := func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*http.Response, error) {
+			if key := req.Header.Get(IdempotencyKeyHeader); key != "" {
+				mu.Lock()
+				keys = append(keys, key)
+				mu.Unlock()
+			}
+			return next.Do(ctx, req)
+		})
+	}
+	client, _, err := NewClientWithURL(context.Background(), token, url, WithMiddleware(recorder))
+	assert.NoError(t, err)
+	return client, &keys
+}
+
+func TestCloneAppIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	client, keys := newKeyRecordingClient(t)
+	src := newTestApp(t, &client)
+	_, err := client.CreateNamespace(ctx, src.ID, &Namespace{ID: "chat"})
+	assert.NoError(t, err)
+	_, err = client.CreateQueue(ctx, src.ID, &NewQueue{Name: "events", Ttl: 60, MaxLength: 100, Region: UsEast1A})
+	assert.NoError(t, err)
+
+	// each create call made by CloneApp has its own key.
+	*keys = nil
+	result, err := client.CloneApp(ContextWithIdempotencyKey(ctx, "deploy"), src.ID, "clone-"+fmt.Sprint(rand.Uint64()), CloneOptions{})
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.DeleteApp(ctx, result.App.ID) })
+	assert.Len(t, *keys, 3)
+	assert.Len(t, slices.Compact(slices.Sorted(slices.Values(*keys))), 3)
+	for _, key := range *keys {
+		assert.True(t, strings.HasPrefix(key, "deploy/"), key)
+	}
+}
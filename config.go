@@ -0,0 +1,462 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+
+	"github.com/ably/ably-control-go/internal/redact"
+	"gopkg.in/yaml.v3"
+)
+
+// AppConfigVersion is the version of the AppConfig format written by
+// ExportApp. Configs with other versions are rejected.
+const AppConfigVersion = 1
+
+// AppConfig is a portable description of the configuration of an app, which
+// can be stored as JSON or YAML.
+//
+// Items refer to each other by ref rather than by server ID: the QueueID of
+// an AmqpTarget holds the ref of a queue in Queues, and the SigningKeyID of
+// a rule target holds the ref of a key in Keys.
+type AppConfig struct {
+	// The version of the format, which is AppConfigVersion.
+	Version int `json:"version"`
+	// The settings of the app.
+	App AppSettings `json:"app"`
+	// The namespaces of the app, which are identified by their IDs.
+	Namespaces []Namespace `json:"namespaces,omitempty"`
+	// The keys of the app.
+	Keys []KeyConfig `json:"keys,omitempty"`
+	// The queues of the app.
+	Queues []QueueConfig `json:"queues,omitempty"`
+	// The rules of the app.
+	Rules []NewRule `json:"rules,omitempty"`
+	// The ingress rules of the app.
+	IngressRules []NewIngressRule `json:"ingressRules,omitempty"`
+}
+
+// AppSettings are the settings of an app in an AppConfig.
+type AppSettings struct {
+	// The application name.
+	Name string `json:"name"`
+	// The application status, "enabled" or "disabled".
+	Status string `json:"status,omitempty"`
+	// Enforce TLS for all connections.
+	TLSOnly bool `json:"tlsOnly"`
+	// The Firebase Cloud Messaging key.
+	FcmKey string `json:"fcmKey,omitempty"`
+	// The Firebase Service Account key.
+	FcmServiceAccount string `json:"fcmServiceAccount,omitempty"`
+	// The Firebase Project ID.
+	FcmProjectId string `json:"fcmProjectId,omitempty"`
+	// The Apple Push Notification service certificate. It is never
+	// exported, as the Control API does not return it.
+	ApnsCertificate string `json:"apnsCertificate,omitempty"`
+	// The Apple Push Notification service private key. It is never
+	// exported, as the Control API does not return it.
+	ApnsPrivateKey string `json:"apnsPrivateKey,omitempty"`
+	// Use the Apple Push Notification service sandbox endpoint.
+	ApnsUseSandboxEndpoint bool `json:"apnsUseSandboxEndpoint"`
+}
+
+// KeyConfig is a key in an AppConfig.
+type KeyConfig struct {
+	// The name rules in the config use to refer to the key.
+	Ref string `json:"ref"`
+	// The name for your API key.
+	Name string `json:"name,omitempty"`
+	// The capabilities that this key has.
+	Capability map[string][]string `json:"capability"`
+	// Enable Revocable Tokens.
+	RevocableTokens bool `json:"revocableTokens,omitempty"`
+	// The complete API key including API secret, if it was exported. It is
+	// only for reference, as a new key always gets a new secret.
+	Key string `json:"key,omitempty"`
+}
+
+// QueueConfig is a queue in an AppConfig.
+type QueueConfig struct {
+	// The name rules in the config use to refer to the queue.
+	Ref string `json:"ref"`
+	// The friendly name of the queue.
+	Name string `json:"name"`
+	// The data center region for the queue.
+	Region Region `json:"region"`
+	// TTL in minutes.
+	Ttl int `json:"ttl,omitempty"`
+	// Message limit in number of messages.
+	MaxLength int `json:"maxLength,omitempty"`
+	// Whether this is a dead letter queue. Dead letter queues are created by
+	// the Control API along with other queues, so they are not created from
+	// the config, but rules may refer to them.
+	DeadLetter bool `json:"deadLetter,omitempty"`
+}
+
+// ParseAppConfig decodes and validates an AppConfig written as JSON or YAML.
+func ParseAppConfig(data []byte) (*AppConfig, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("control: app config: %w", err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("control: app config: %w", err)
+	}
+	var cfg AppConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("control: app config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// YAML returns the config written as YAML.
+func (cfg *AppConfig) YAML() ([]byte, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// Validate checks that the config has a supported version, that refs and
+// namespace IDs are unique, and that rules only refer to keys and queues in
+// the config.
+func (cfg *AppConfig) Validate() error {
+	if cfg.Version != AppConfigVersion {
+		return fmt.Errorf("control: app config: unsupported version %d", cfg.Version)
+	}
+	if cfg.App.Name == "" {
+		return fmt.Errorf("control: app config: the app has no name")
+	}
+	namespaces := map[string]bool{}
+	for _, namespace := range cfg.Namespaces {
+		if namespaces[namespace.ID] {
+			return fmt.Errorf("control: app config: duplicate namespace %q", namespace.ID)
+		}
+		namespaces[namespace.ID] = true
+	}
+	refs := map[string]map[string]bool{"key": {}, "queue": {}}
+	for _, key := range cfg.Keys {
+		if key.Ref == "" || refs["key"][key.Ref] {
+			return fmt.Errorf("control: app config: missing or duplicate key ref %q", key.Ref)
+		}
+		refs["key"][key.Ref] = true
+	}
+	for _, queue := range cfg.Queues {
+		if queue.Ref == "" || refs["queue"][queue.Ref] {
+			return fmt.Errorf("control: app config: missing or duplicate queue ref %q", queue.Ref)
+		}
+		refs["queue"][queue.Ref] = true
+	}
+	for i, rule := range cfg.Rules {
+		if rule.Target == nil {
+			return fmt.Errorf("control: app config: rules[%d] has no target", i)
+		}
+		if resource, ref := targetReference(rule.Target); ref != "" && !refs[resource][ref] {
+			return fmt.Errorf("control: app config: rules[%d] refers to unknown %s %q", i, resource, ref)
+		}
+	}
+	for i, rule := range cfg.IngressRules {
+		if rule.Target == nil {
+			return fmt.Errorf("control: app config: ingressRules[%d] has no target", i)
+		}
+	}
+	return nil
+}
+
+// ExportOptions controls what ExportApp includes.
+type ExportOptions struct {
+	// Secrets includes the secrets of keys, push credentials and rule
+	// targets. Otherwise secrets are replaced by "[REDACTED]", and key
+	// secrets are left out.
+	Secrets bool
+}
+
+// ExportApp returns the configuration of the app with the specified ID.
+//
+// Keys and queues are given refs based on their names. Revoked keys are
+// left out, and references to them in rule targets are left empty.
+func (c *Client) ExportApp(ctx context.Context, appID string, opts ExportOptions) (*AppConfig, error) {
+	app, err := c.App(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := c.Namespaces(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := c.Keys(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	queues, err := c.Queues(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	rules, ingressRules, err := c.appRules(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &AppConfig{
+		Version: AppConfigVersion,
+		App: AppSettings{
+			Name:                   app.Name,
+			Status:                 app.Status,
+			TLSOnly:                app.TLSOnly,
+			FcmKey:                 app.FcmKey,
+			FcmServiceAccount:      app.FcmServiceAccount,
+			FcmProjectId:           app.FcmProjectId,
+			ApnsUseSandboxEndpoint: app.ApnsUseSandboxEndpoint,
+		},
+		Namespaces: namespaces,
+	}
+	keyRefs := map[string]string{}
+	used := map[string]bool{}
+	for _, key := range keys {
+		if key.Status != 0 {
+			keyRefs[key.ID] = ""
+			continue
+		}
+		keyConfig := KeyConfig{
+			Ref:             uniqueRef(used, key.Name, "key"),
+			Name:            key.Name,
+			Capability:      key.Capability,
+			RevocableTokens: key.RevocableTokens,
+		}
+		if opts.Secrets {
+			keyConfig.Key = key.Key
+		}
+		keyRefs[key.ID] = keyConfig.Ref
+		cfg.Keys = append(cfg.Keys, keyConfig)
+	}
+	queueRefs := map[string]string{}
+	used = map[string]bool{}
+	for _, queue := range queues {
+		queueConfig := QueueConfig{
+			Ref:        uniqueRef(used, queue.Name, queue.Name+"-"+string(queue.Region), "queue"),
+			Name:       queue.Name,
+			Region:     queue.Region,
+			Ttl:        queue.Ttl,
+			MaxLength:  queue.MaxLength,
+			DeadLetter: queue.DeadLetter,
+		}
+		queueRefs[queue.ID] = queueConfig.Ref
+		cfg.Queues = append(cfg.Queues, queueConfig)
+	}
+	for _, rule := range rules {
+		target, err := remapTarget(rule.Target, queueRefs, keyRefs)
+		if err != nil {
+			return nil, fmt.Errorf("control: exporting rule %s: %w", rule.ID, err)
+		}
+		cfg.Rules = append(cfg.Rules, NewRule{
+			Status:      rule.Status,
+			RequestMode: rule.RequestMode,
+			Source:      rule.Source,
+			Target:      target,
+		})
+	}
+	for _, rule := range ingressRules {
+		cfg.IngressRules = append(cfg.IngressRules, NewIngressRule{Status: rule.Status, Target: rule.Target})
+	}
+	if !opts.Secrets {
+		return redactConfig(cfg)
+	}
+	return cfg, nil
+}
+
+// uniqueRef returns the first non-empty candidate which is not in used, or
+// if they are all used the first one with a number appended, and adds it to
+// used.
+func uniqueRef(used map[string]bool, candidates ...string) string {
+	base := ""
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if base == "" {
+			base = candidate
+		}
+		if !used[candidate] {
+			used[candidate] = true
+			return candidate
+		}
+	}
+	ref := base
+	for n := 2; used[ref]; n++ {
+		ref = fmt.Sprintf("%s-%d", base, n)
+	}
+	used[ref] = true
+	return ref
+}
+
+// redactConfig returns a copy of cfg with secrets redacted.
+func redactConfig(cfg *AppConfig) (*AppConfig, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var redacted AppConfig
+	if err := json.Unmarshal(redact.JSON(data), &redacted); err != nil {
+		return nil, err
+	}
+	return &redacted, nil
+}
+
+// hasRedacted reports whether the JSON encoding of v contains values which
+// were redacted by ExportApp.
+func hasRedacted(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(data, []byte(redact.Placeholder)) ||
+		bytes.Contains(data, []byte(neturl.PathEscape(redact.Placeholder)))
+}
+
+// ImportResult describes the app created by ImportApp.
+type ImportResult struct {
+	// The new app.
+	App App
+	// Keys maps the refs of the config's keys to the IDs of the keys created
+	// for them.
+	Keys map[string]string
+	// Queues maps the refs of the config's queues to the IDs of the queues
+	// created for them.
+	Queues map[string]string
+	// Rules holds the IDs of the rules created for the config's rules, in
+	// the same order, with an empty ID for any rule which was not created.
+	Rules []string
+	// IngressRules holds the IDs of the ingress rules created for the
+	// config's ingress rules, in the same order, with an empty ID for any
+	// which was not created.
+	IngressRules []string
+	// NotImported lists the items of the config which were not imported,
+	// identified by ref, by namespace ID, or for rules by position, such as
+	// "rules[0]".
+	NotImported []NotCopied
+}
+
+func (r *ImportResult) notImported(resource, id, reason string, err error) {
+	r.NotImported = append(r.NotImported, NotCopied{Resource: resource, ID: id, Reason: reason, Err: err})
+}
+
+// ImportApp creates a new app with the configuration cfg in the client's
+// account.
+//
+// Secrets which were redacted by ExportApp can't be imported: redacted push
+// credentials are left empty, and rules and ingress rules whose targets
+// contain redacted secrets are not created.
+//
+// An error is returned if cfg is not valid or the new app can't be created.
+// Items which fail to be created after that are listed in the result's
+// NotImported field, and the rest are still created.
+//
+// If ctx carries an idempotency key, see ContextWithIdempotencyKey, each
+// create call uses a key derived from it.
+func (c *Client) ImportApp(ctx context.Context, cfg *AppConfig) (ImportResult, error) {
+	result := ImportResult{
+		Keys:         map[string]string{},
+		Queues:       map[string]string{},
+		Rules:        make([]string, len(cfg.Rules)),
+		IngressRules: make([]string, len(cfg.IngressRules)),
+	}
+	if err := cfg.Validate(); err != nil {
+		return result, err
+	}
+
+	newApp := NewApp{
+		Name:                   cfg.App.Name,
+		Status:                 cfg.App.Status,
+		TLSOnly:                cfg.App.TLSOnly,
+		FcmKey:                 cfg.App.FcmKey,
+		FcmServiceAccount:      cfg.App.FcmServiceAccount,
+		FcmProjectId:           cfg.App.FcmProjectId,
+		ApnsCertificate:        cfg.App.ApnsCertificate,
+		ApnsPrivateKey:         cfg.App.ApnsPrivateKey,
+		ApnsUseSandboxEndpoint: cfg.App.ApnsUseSandboxEndpoint,
+	}
+	if hasRedacted(&newApp) {
+		newApp.FcmKey = ""
+		newApp.FcmServiceAccount = ""
+		newApp.ApnsCertificate = ""
+		newApp.ApnsPrivateKey = ""
+		result.notImported("app", cfg.App.Name, "the push credentials are redacted", nil)
+	}
+	app, err := c.CreateApp(deriveIdempotencyKey(ctx, "app"), &newApp)
+	if err != nil {
+		return result, err
+	}
+	result.App = app
+
+	for _, namespace := range cfg.Namespaces {
+		if _, err := c.CreateNamespace(deriveIdempotencyKey(ctx, "namespace/"+namespace.ID), app.ID, &namespace); err != nil {
+			result.notImported("namespace", namespace.ID, "create failed", err)
+		}
+	}
+	ids, errs, err := c.createKeys(ctx, app.ID, cfg.Keys)
+	if err != nil {
+		return result, err
+	}
+	for i, key := range cfg.Keys {
+		if errs[i] != nil {
+			result.notImported("key", key.Ref, "create failed", errs[i])
+			continue
+		}
+		result.Keys[key.Ref] = ids[i]
+	}
+	ids, errs, err = c.createQueues(ctx, app.ID, cfg.Queues)
+	if err != nil {
+		return result, err
+	}
+	for i, queue := range cfg.Queues {
+		if errs[i] != nil {
+			result.notImported("queue", queue.Ref, "create failed", errs[i])
+			continue
+		}
+		result.Queues[queue.Ref] = ids[i]
+	}
+	for i, rule := range cfg.Rules {
+		id := fmt.Sprintf("rules[%d]", i)
+		if hasRedacted(rule.Target) {
+			result.notImported("rule", id, "the target contains redacted secrets", nil)
+			continue
+		}
+		target, err := remapTarget(rule.Target, result.Queues, result.Keys)
+		if err != nil {
+			result.notImported("rule", id, err.Error(), nil)
+			continue
+		}
+		rule.Target = target
+		created, err := c.CreateRule(deriveIdempotencyKey(ctx, id), app.ID, &rule)
+		if err != nil {
+			result.notImported("rule", id, "create failed", err)
+			continue
+		}
+		result.Rules[i] = created.ID
+	}
+	for i, rule := range cfg.IngressRules {
+		id := fmt.Sprintf("ingressRules[%d]", i)
+		if hasRedacted(rule.Target) {
+			result.notImported("ingress rule", id, "the target contains redacted secrets", nil)
+			continue
+		}
+		created, err := c.CreateIngressRule(deriveIdempotencyKey(ctx, id), app.ID, &rule)
+		if err != nil {
+			result.notImported("ingress rule", id, "create failed", err)
+			continue
+		}
+		result.IngressRules[i] = created.ID
+	}
+	return result, nil
+}
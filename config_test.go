@@ -0,0 +1,174 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImportApp(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	f := newTestFixture(t, &client)
+	src := f.App
+
+	cfg, err := client.ExportApp(ctx, src.ID, ExportOptions{Secrets: true})
+	assert.NoError(t, err)
+	assert.Equal(t, AppConfigVersion, cfg.Version)
+	assert.Equal(t, src.Name, cfg.App.Name)
+	assert.Equal(t, []Namespace{{ID: "chat", Persisted: true}}, cfg.Namespaces)
+	assert.Equal(t, []KeyConfig{{Ref: "signing", Name: "signing", Capability: f.Key.Capability, Key: f.Key.Key}}, cfg.Keys)
+	var refs []string
+	for _, queue := range cfg.Queues {
+		refs = append(refs, queue.Ref)
+	}
+	assert.Equal(t, []string{"events", "deadletter", "events-eu-west-1-a", "deadletter-eu-west-1-a"}, refs)
+	assert.True(t, cfg.Queues[3].DeadLetter)
+	assert.Equal(t, "events-eu-west-1-a", cfg.Rules[0].Target.(*AmqpTarget).QueueID)
+	assert.Equal(t, "deadletter-eu-west-1-a", cfg.Rules[3].Target.(*AmqpTarget).QueueID)
+	assert.Equal(t, "signing", cfg.Rules[1].Target.(*HttpTarget).SigningKeyID)
+	assert.Equal(t, "s3cr3t", cfg.Rules[2].Target.(*KafkaTarget).Authentication.Sasl.Password)
+	assert.Len(t, cfg.IngressRules, 1)
+
+	data, err := cfg.YAML()
+	assert.NoError(t, err)
+	parsed, err := ParseAppConfig(data)
+	assert.NoError(t, err)
+	assert.Equal(t, cfg, parsed)
+
+	cfg.App.Name = "import-" + fmt.Sprint(rand.Uint64())
+	result, err := client.ImportApp(ctx, cfg)
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.DeleteApp(ctx, result.App.ID) })
+	assert.Empty(t, result.NotImported)
+	assert.Equal(t, cfg.App.Name, result.App.Name)
+
+	rule, err := client.Rule(ctx, result.App.ID, result.Rules[0])
+	assert.NoError(t, err)
+	assert.Equal(t, result.Queues["events-eu-west-1-a"], rule.Target.(*AmqpTarget).QueueID)
+	rule, err = client.Rule(ctx, result.App.ID, result.Rules[1])
+	assert.NoError(t, err)
+	assert.Equal(t, result.Keys["signing"], rule.Target.(*HttpTarget).SigningKeyID)
+	// the rule refers to the dead letter queue created along with the
+	// imported queue in the same region.
+	rule, err = client.Rule(ctx, result.App.ID, result.Rules[3])
+	assert.NoError(t, err)
+	assert.Equal(t, result.App.ID+":eu-west-1-a:deadletter", rule.Target.(*AmqpTarget).QueueID)
+	assert.Equal(t, result.Queues["deadletter-eu-west-1-a"], rule.Target.(*AmqpTarget).QueueID)
+	_, err = client.IngressRule(ctx, result.App.ID, result.IngressRules[0])
+	assert.NoError(t, err)
+
+	cfg, err = client.ExportApp(ctx, src.ID, ExportOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.Keys[0].Key)
+	assert.Equal(t, "[REDACTED]", cfg.Rules[2].Target.(*KafkaTarget).Authentication.Sasl.Password)
+	assert.NotContains(t, cfg.IngressRules[0].Target.(*IngressMongoTarget).Url, "s3cr3t")
+
+	cfg.App.Name = "import-" + fmt.Sprint(rand.Uint64())
+	redacted, err := client.ImportApp(ctx, cfg)
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.DeleteApp(ctx, redacted.App.ID) })
+	assert.Equal(t, []NotCopied{
+		{Resource: "rule", ID: "rules[2]", Reason: "the target contains redacted secrets"},
+		{Resource: "ingress rule", ID: "ingressRules[0]", Reason: "the target contains redacted secrets"},
+	}, redacted.NotImported)
+	assert.NotEmpty(t, redacted.Rules[0])
+	assert.Empty(t, redacted.Rules[2])
+}
+
+func TestParseAppConfig(t *testing.T) {
+	cfg, err := ParseAppConfig([]byte(`
+version: 1
+app:
+  name: my-app
+  tlsOnly: true
+keys:
+  - ref: webhooks
+    name: Webhooks
+    capability:
+      "*": [publish]
+rules:
+  - ruleType: http
+    source:
+      type: channel.message
+    target:
+      url: https://example.com
+      signingKeyId: webhooks
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, &AppConfig{
+		Version: 1,
+		App:     AppSettings{Name: "my-app", TLSOnly: true},
+		Keys:    []KeyConfig{{Ref: "webhooks", Name: "Webhooks", Capability: map[string][]string{"*": {"publish"}}}},
+		Rules: []NewRule{{
+			Source: Source{Type: ChannelMessage},
+			Target: &HttpTarget{Url: "https://example.com", SigningKeyID: "webhooks"},
+		}},
+	}, cfg)
+}
+
+func TestAppConfigValidate(t *testing.T) {
+	valid := func() AppConfig {
+		return AppConfig{
+			Version: AppConfigVersion,
+			App:     AppSettings{Name: "app"},
+			Keys:    []KeyConfig{{Ref: "key"}},
+			Queues:  []QueueConfig{{Ref: "queue"}},
+			Rules:   []NewRule{{Target: &AmqpTarget{QueueID: "queue"}}, {Target: &HttpTarget{SigningKeyID: "key"}}},
+		}
+	}
+	cfg := valid()
+	assert.NoError(t, cfg.Validate())
+
+	for name, test := range map[string]struct {
+		change func(cfg *AppConfig)
+		err    string
+	}{
+		"version":       {func(cfg *AppConfig) { cfg.Version = 2 }, "unsupported version 2"},
+		"name":          {func(cfg *AppConfig) { cfg.App.Name = "" }, "the app has no name"},
+		"namespace":     {func(cfg *AppConfig) { cfg.Namespaces = []Namespace{{ID: "a"}, {ID: "a"}} }, `duplicate namespace "a"`},
+		"key ref":       {func(cfg *AppConfig) { cfg.Keys = append(cfg.Keys, KeyConfig{Ref: "key"}) }, `duplicate key ref "key"`},
+		"queue ref":     {func(cfg *AppConfig) { cfg.Queues[0].Ref = "" }, `missing or duplicate queue ref ""`},
+		"unknown queue": {func(cfg *AppConfig) { cfg.Rules[0].Target = &AmqpTarget{QueueID: "key"} }, `rules[0] refers to unknown queue "key"`},
+		"unknown key":   {func(cfg *AppConfig) { cfg.Rules[1].Target = &HttpTarget{SigningKeyID: "other"} }, `rules[1] refers to unknown key "other"`},
+		"target":        {func(cfg *AppConfig) { cfg.Rules[0].Target = nil }, "rules[0] has no target"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid()
+			test.change(&cfg)
+			err := cfg.Validate()
+			assert.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestUniqueRef(t *testing.T) {
+	used := map[string]bool{}
+	assert.Equal(t, "events", uniqueRef(used, "events", "events-us"))
+	assert.Equal(t, "events-us", uniqueRef(used, "events", "events-us"))
+	assert.Equal(t, "events-2", uniqueRef(used, "events", "events-us"))
+	assert.Equal(t, "key", uniqueRef(used, "", "key"))
+	assert.Equal(t, "key-2", uniqueRef(used, "", "key"))
+}
+
+func TestImportAppIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	client, keys := newKeyRecordingClient(t)
+	cfg := &AppConfig{
+		Version:    AppConfigVersion,
+		App:        AppSettings{Name: "import-" + fmt.Sprint(rand.Uint64())},
+		Namespaces: []Namespace{{ID: "chat"}},
+		Keys:       []KeyConfig{{Ref: "key", Name: "key", Capability: map[string][]string{"*": {"publish"}}}},
+		Queues:     []QueueConfig{{Ref: "events", Name: "events", Region: UsEast1A, Ttl: 60, MaxLength: 100}},
+		Rules:      []NewRule{{Source: Source{Type: ChannelMessage}, Target: &AmqpTarget{QueueID: "events"}}},
+	}
+
+	// each create call made by ImportApp has its own key.
+	result, err := client.ImportApp(ContextWithIdempotencyKey(ctx, "deploy"), cfg)
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.DeleteApp(ctx, result.App.ID) })
+	assert.Equal(t, []string{"deploy/app", "deploy/namespace/chat", "deploy/key/key", "deploy/queue/events", "deploy/rules[0]"}, *keys)
+}
@@ -0,0 +1,504 @@
+// Package controlmock provides a fake control.ControlAPI which records the
+// calls made to it, for testing code which depends on control.ControlAPI or
+// one of the per-resource interfaces such as control.AppsAPI.
+//
+//	fake := &controlmock.Fake{
+//		CreateAppFunc: func(ctx context.Context, app *control.NewApp) (control.App, error) {
+//			return control.App{ID: "app", Name: app.Name}, nil
+//		},
+//	}
+//	err := provision(ctx, fake)
+//	calls := fake.CallsTo("CreateApp")
+package controlmock
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	control "github.com/ably/ably-control-go"
+)
+
+// Call is a call made to a Fake.
+type Call struct {
+	// The name of the method, such as "CreateApp".
+	Method string
+	// The arguments following the context, such as the app ID and the
+	// *control.NewKey passed to CreateKey.
+	Args []interface{}
+}
+
+// Fake is a control.ControlAPI which records every call made to it, and
+// returns the result of the matching function field, such as CreateAppFunc.
+// If the field is nil the call returns zero values and a nil error.
+//
+// Iterators such as AllApps use their own function field if it is set, and
+// otherwise yield the result of the matching slice function, such as
+// AppsFunc.
+//
+// A Fake is safe for concurrent use, but its function fields must not be
+// changed while it is in use.
+type Fake struct {
+	MeFunc                func(ctx context.Context) (control.Me, error)
+	AppsFunc              func(ctx context.Context) ([]control.App, error)
+	AllAppsFunc           func(ctx context.Context) iter.Seq2[control.App, error]
+	AppFunc               func(ctx context.Context, id string) (control.App, error)
+	FindAppByNameFunc     func(ctx context.Context, name string) (control.App, error)
+	CreateAppFunc         func(ctx context.Context, app *control.NewApp) (control.App, error)
+	UpdateAppFunc         func(ctx context.Context, id string, app *control.AppUpdate) (control.App, error)
+	DeleteAppFunc         func(ctx context.Context, id string) error
+	KeysFunc              func(ctx context.Context, appID string) ([]control.Key, error)
+	AllKeysFunc           func(ctx context.Context, appID string) iter.Seq2[control.Key, error]
+	KeyFunc               func(ctx context.Context, appID, keyID string) (control.Key, error)
+	FindKeyByNameFunc     func(ctx context.Context, appID, name string) (control.Key, error)
+	CreateKeyFunc         func(ctx context.Context, appID string, key *control.NewKey) (control.Key, error)
+	UpdateKeyFunc         func(ctx context.Context, appID, keyID string, key *control.KeyUpdate) (control.Key, error)
+	RevokeKeyFunc         func(ctx context.Context, appID, keyID string) error
+	NamespacesFunc        func(ctx context.Context, appID string) ([]control.Namespace, error)
+	AllNamespacesFunc     func(ctx context.Context, appID string) iter.Seq2[control.Namespace, error]
+	NamespaceFunc         func(ctx context.Context, appID, namespaceID string) (control.Namespace, error)
+	CreateNamespaceFunc   func(ctx context.Context, appID string, namespace *control.Namespace) (control.Namespace, error)
+	UpdateNamespaceFunc   func(ctx context.Context, appID, namespaceID string, namespace *control.NamespaceUpdate) (control.Namespace, error)
+	DeleteNamespaceFunc   func(ctx context.Context, appID, namespaceID string) error
+	QueuesFunc            func(ctx context.Context, appID string) ([]control.Queue, error)
+	AllQueuesFunc         func(ctx context.Context, appID string) iter.Seq2[control.Queue, error]
+	QueueFunc             func(ctx context.Context, appID, queueID string) (control.Queue, error)
+	FindQueueByNameFunc   func(ctx context.Context, appID, name string) (control.Queue, error)
+	CreateQueueFunc       func(ctx context.Context, appID string, queue *control.NewQueue) (control.Queue, error)
+	DeleteQueueFunc       func(ctx context.Context, appID, queueID string) error
+	RulesFunc             func(ctx context.Context, appID string) ([]control.Rule, error)
+	AllRulesFunc          func(ctx context.Context, appID string) iter.Seq2[control.Rule, error]
+	RuleFunc              func(ctx context.Context, appID, ruleID string) (control.Rule, error)
+	CreateRuleFunc        func(ctx context.Context, appID string, rule *control.NewRule) (control.Rule, error)
+	UpdateRuleFunc        func(ctx context.Context, appID, ruleID string, rule *control.RuleUpdate) (control.Rule, error)
+	DeleteRuleFunc        func(ctx context.Context, appID, ruleID string) error
+	IngressRulesFunc      func(ctx context.Context, appID string) ([]control.IngressRule, error)
+	AllIngressRulesFunc   func(ctx context.Context, appID string) iter.Seq2[control.IngressRule, error]
+	IngressRuleFunc       func(ctx context.Context, appID, ruleID string) (control.IngressRule, error)
+	CreateIngressRuleFunc func(ctx context.Context, appID string, rule *control.NewIngressRule) (control.IngressRule, error)
+	UpdateIngressRuleFunc func(ctx context.Context, appID, ruleID string, rule *control.NewIngressRule) (control.IngressRule, error)
+	DeleteIngressRuleFunc func(ctx context.Context, appID, ruleID string) error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ control.ControlAPI = (*Fake)(nil)
+
+// Calls returns the calls made so far, in the order they were made.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallsTo returns the calls made so far to the method with the given name.
+func (f *Fake) CallsTo(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []Call
+	for _, call := range f.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls made so far.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+func (f *Fake) record(method string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+}
+
+// seq returns an iterator over items, or over err alone if it is not nil.
+func seq[T any](items []T, err error) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Me implements control.ControlAPI.
+func (f *Fake) Me(ctx context.Context) (control.Me, error) {
+	f.record("Me")
+	if f.MeFunc != nil {
+		return f.MeFunc(ctx)
+	}
+	return control.Me{}, nil
+}
+
+// Apps implements control.ControlAPI.
+func (f *Fake) Apps(ctx context.Context) ([]control.App, error) {
+	f.record("Apps")
+	if f.AppsFunc != nil {
+		return f.AppsFunc(ctx)
+	}
+	return nil, nil
+}
+
+// AllApps implements control.ControlAPI.
+func (f *Fake) AllApps(ctx context.Context) iter.Seq2[control.App, error] {
+	f.record("AllApps")
+	if f.AllAppsFunc != nil {
+		return f.AllAppsFunc(ctx)
+	}
+	if f.AppsFunc != nil {
+		return seq(f.AppsFunc(ctx))
+	}
+	return seq[control.App](nil, nil)
+}
+
+// App implements control.ControlAPI.
+func (f *Fake) App(ctx context.Context, id string) (control.App, error) {
+	f.record("App", id)
+	if f.AppFunc != nil {
+		return f.AppFunc(ctx, id)
+	}
+	return control.App{}, nil
+}
+
+// FindAppByName implements control.ControlAPI.
+func (f *Fake) FindAppByName(ctx context.Context, name string) (control.App, error) {
+	f.record("FindAppByName", name)
+	if f.FindAppByNameFunc != nil {
+		return f.FindAppByNameFunc(ctx, name)
+	}
+	return control.App{}, nil
+}
+
+// CreateApp implements control.ControlAPI.
+func (f *Fake) CreateApp(ctx context.Context, app *control.NewApp) (control.App, error) {
+	f.record("CreateApp", app)
+	if f.CreateAppFunc != nil {
+		return f.CreateAppFunc(ctx, app)
+	}
+	return control.App{}, nil
+}
+
+// UpdateApp implements control.ControlAPI.
+func (f *Fake) UpdateApp(ctx context.Context, id string, app *control.AppUpdate) (control.App, error) {
+	f.record("UpdateApp", id, app)
+	if f.UpdateAppFunc != nil {
+		return f.UpdateAppFunc(ctx, id, app)
+	}
+	return control.App{}, nil
+}
+
+// DeleteApp implements control.ControlAPI.
+func (f *Fake) DeleteApp(ctx context.Context, id string) error {
+	f.record("DeleteApp", id)
+	if f.DeleteAppFunc != nil {
+		return f.DeleteAppFunc(ctx, id)
+	}
+	return nil
+}
+
+// Keys implements control.ControlAPI.
+func (f *Fake) Keys(ctx context.Context, appID string) ([]control.Key, error) {
+	f.record("Keys", appID)
+	if f.KeysFunc != nil {
+		return f.KeysFunc(ctx, appID)
+	}
+	return nil, nil
+}
+
+// AllKeys implements control.ControlAPI.
+func (f *Fake) AllKeys(ctx context.Context, appID string) iter.Seq2[control.Key, error] {
+	f.record("AllKeys", appID)
+	if f.AllKeysFunc != nil {
+		return f.AllKeysFunc(ctx, appID)
+	}
+	if f.KeysFunc != nil {
+		return seq(f.KeysFunc(ctx, appID))
+	}
+	return seq[control.Key](nil, nil)
+}
+
+// Key implements control.ControlAPI.
+func (f *Fake) Key(ctx context.Context, appID, keyID string) (control.Key, error) {
+	f.record("Key", appID, keyID)
+	if f.KeyFunc != nil {
+		return f.KeyFunc(ctx, appID, keyID)
+	}
+	return control.Key{}, nil
+}
+
+// FindKeyByName implements control.ControlAPI.
+func (f *Fake) FindKeyByName(ctx context.Context, appID, name string) (control.Key, error) {
+	f.record("FindKeyByName", appID, name)
+	if f.FindKeyByNameFunc != nil {
+		return f.FindKeyByNameFunc(ctx, appID, name)
+	}
+	return control.Key{}, nil
+}
+
+// CreateKey implements control.ControlAPI.
+func (f *Fake) CreateKey(ctx context.Context, appID string, key *control.NewKey) (control.Key, error) {
+	f.record("CreateKey", appID, key)
+	if f.CreateKeyFunc != nil {
+		return f.CreateKeyFunc(ctx, appID, key)
+	}
+	return control.Key{}, nil
+}
+
+// UpdateKey implements control.ControlAPI.
+func (f *Fake) UpdateKey(ctx context.Context, appID, keyID string, key *control.KeyUpdate) (control.Key, error) {
+	f.record("UpdateKey", appID, keyID, key)
+	if f.UpdateKeyFunc != nil {
+		return f.UpdateKeyFunc(ctx, appID, keyID, key)
+	}
+	return control.Key{}, nil
+}
+
+// RevokeKey implements control.ControlAPI.
+func (f *Fake) RevokeKey(ctx context.Context, appID, keyID string) error {
+	f.record("RevokeKey", appID, keyID)
+	if f.RevokeKeyFunc != nil {
+		return f.RevokeKeyFunc(ctx, appID, keyID)
+	}
+	return nil
+}
+
+// Namespaces implements control.ControlAPI.
+func (f *Fake) Namespaces(ctx context.Context, appID string) ([]control.Namespace, error) {
+	f.record("Namespaces", appID)
+	if f.NamespacesFunc != nil {
+		return f.NamespacesFunc(ctx, appID)
+	}
+	return nil, nil
+}
+
+// AllNamespaces implements control.ControlAPI.
+func (f *Fake) AllNamespaces(ctx context.Context, appID string) iter.Seq2[control.Namespace, error] {
+	f.record("AllNamespaces", appID)
+	if f.AllNamespacesFunc != nil {
+		return f.AllNamespacesFunc(ctx, appID)
+	}
+	if f.NamespacesFunc != nil {
+		return seq(f.NamespacesFunc(ctx, appID))
+	}
+	return seq[control.Namespace](nil, nil)
+}
+
+// Namespace implements control.ControlAPI.
+func (f *Fake) Namespace(ctx context.Context, appID, namespaceID string) (control.Namespace, error) {
+	f.record("Namespace", appID, namespaceID)
+	if f.NamespaceFunc != nil {
+		return f.NamespaceFunc(ctx, appID, namespaceID)
+	}
+	return control.Namespace{}, nil
+}
+
+// CreateNamespace implements control.ControlAPI.
+func (f *Fake) CreateNamespace(ctx context.Context, appID string, namespace *control.Namespace) (control.Namespace, error) {
+	f.record("CreateNamespace", appID, namespace)
+	if f.CreateNamespaceFunc != nil {
+		return f.CreateNamespaceFunc(ctx, appID, namespace)
+	}
+	return control.Namespace{}, nil
+}
+
+// UpdateNamespace implements control.ControlAPI.
+func (f *Fake) UpdateNamespace(ctx context.Context, appID, namespaceID string, namespace *control.NamespaceUpdate) (control.Namespace, error) {
+	f.record("UpdateNamespace", appID, namespaceID, namespace)
+	if f.UpdateNamespaceFunc != nil {
+		return f.UpdateNamespaceFunc(ctx, appID, namespaceID, namespace)
+	}
+	return control.Namespace{}, nil
+}
+
+// DeleteNamespace implements control.ControlAPI.
+func (f *Fake) DeleteNamespace(ctx context.Context, appID, namespaceID string) error {
+	f.record("DeleteNamespace", appID, namespaceID)
+	if f.DeleteNamespaceFunc != nil {
+		return f.DeleteNamespaceFunc(ctx, appID, namespaceID)
+	}
+	return nil
+}
+
+// Queues implements control.ControlAPI.
+func (f *Fake) Queues(ctx context.Context, appID string) ([]control.Queue, error) {
+	f.record("Queues", appID)
+	if f.QueuesFunc != nil {
+		return f.QueuesFunc(ctx, appID)
+	}
+	return nil, nil
+}
+
+// AllQueues implements control.ControlAPI.
+func (f *Fake) AllQueues(ctx context.Context, appID string) iter.Seq2[control.Queue, error] {
+	f.record("AllQueues", appID)
+	if f.AllQueuesFunc != nil {
+		return f.AllQueuesFunc(ctx, appID)
+	}
+	if f.QueuesFunc != nil {
+		return seq(f.QueuesFunc(ctx, appID))
+	}
+	return seq[control.Queue](nil, nil)
+}
+
+// Queue implements control.ControlAPI.
+func (f *Fake) Queue(ctx context.Context, appID, queueID string) (control.Queue, error) {
+	f.record("Queue", appID, queueID)
+	if f.QueueFunc != nil {
+		return f.QueueFunc(ctx, appID, queueID)
+	}
+	return control.Queue{}, nil
+}
+
+// FindQueueByName implements control.ControlAPI.
+func (f *Fake) FindQueueByName(ctx context.Context, appID, name string) (control.Queue, error) {
+	f.record("FindQueueByName", appID, name)
+	if f.FindQueueByNameFunc != nil {
+		return f.FindQueueByNameFunc(ctx, appID, name)
+	}
+	return control.Queue{}, nil
+}
+
+// CreateQueue implements control.ControlAPI.
+func (f *Fake) CreateQueue(ctx context.Context, appID string, queue *control.NewQueue) (control.Queue, error) {
+	f.record("CreateQueue", appID, queue)
+	if f.CreateQueueFunc != nil {
+		return f.CreateQueueFunc(ctx, appID, queue)
+	}
+	return control.Queue{}, nil
+}
+
+// DeleteQueue implements control.ControlAPI.
+func (f *Fake) DeleteQueue(ctx context.Context, appID, queueID string) error {
+	f.record("DeleteQueue", appID, queueID)
+	if f.DeleteQueueFunc != nil {
+		return f.DeleteQueueFunc(ctx, appID, queueID)
+	}
+	return nil
+}
+
+// Rules implements control.ControlAPI.
+func (f *Fake) Rules(ctx context.Context, appID string) ([]control.Rule, error) {
+	f.record("Rules", appID)
+	if f.RulesFunc != nil {
+		return f.RulesFunc(ctx, appID)
+	}
+	return nil, nil
+}
+
+// AllRules implements control.ControlAPI.
+func (f *Fake) AllRules(ctx context.Context, appID string) iter.Seq2[control.Rule, error] {
+	f.record("AllRules", appID)
+	if f.AllRulesFunc != nil {
+		return f.AllRulesFunc(ctx, appID)
+	}
+	if f.RulesFunc != nil {
+		return seq(f.RulesFunc(ctx, appID))
+	}
+	return seq[control.Rule](nil, nil)
+}
+
+// Rule implements control.ControlAPI.
+func (f *Fake) Rule(ctx context.Context, appID, ruleID string) (control.Rule, error) {
+	f.record("Rule", appID, ruleID)
+	if f.RuleFunc != nil {
+		return f.RuleFunc(ctx, appID, ruleID)
+	}
+	return control.Rule{}, nil
+}
+
+// CreateRule implements control.ControlAPI.
+func (f *Fake) CreateRule(ctx context.Context, appID string, rule *control.NewRule) (control.Rule, error) {
+	f.record("CreateRule", appID, rule)
+	if f.CreateRuleFunc != nil {
+		return f.CreateRuleFunc(ctx, appID, rule)
+	}
+	return control.Rule{}, nil
+}
+
+// UpdateRule implements control.ControlAPI.
+func (f *Fake) UpdateRule(ctx context.Context, appID, ruleID string, rule *control.RuleUpdate) (control.Rule, error) {
+	f.record("UpdateRule", appID, ruleID, rule)
+	if f.UpdateRuleFunc != nil {
+		return f.UpdateRuleFunc(ctx, appID, ruleID, rule)
+	}
+	return control.Rule{}, nil
+}
+
+// DeleteRule implements control.ControlAPI.
+func (f *Fake) DeleteRule(ctx context.Context, appID, ruleID string) error {
+	f.record("DeleteRule", appID, ruleID)
+	if f.DeleteRuleFunc != nil {
+		return f.DeleteRuleFunc(ctx, appID, ruleID)
+	}
+	return nil
+}
+
+// IngressRules implements control.ControlAPI.
+func (f *Fake) IngressRules(ctx context.Context, appID string) ([]control.IngressRule, error) {
+	f.record("IngressRules", appID)
+	if f.IngressRulesFunc != nil {
+		return f.IngressRulesFunc(ctx, appID)
+	}
+	return nil, nil
+}
+
+// AllIngressRules implements control.ControlAPI.
+func (f *Fake) AllIngressRules(ctx context.Context, appID string) iter.Seq2[control.IngressRule, error] {
+	f.record("AllIngressRules", appID)
+	if f.AllIngressRulesFunc != nil {
+		return f.AllIngressRulesFunc(ctx, appID)
+	}
+	if f.IngressRulesFunc != nil {
+		return seq(f.IngressRulesFunc(ctx, appID))
+	}
+	return seq[control.IngressRule](nil, nil)
+}
+
+// IngressRule implements control.ControlAPI.
+func (f *Fake) IngressRule(ctx context.Context, appID, ruleID string) (control.IngressRule, error) {
+	f.record("IngressRule", appID, ruleID)
+	if f.IngressRuleFunc != nil {
+		return f.IngressRuleFunc(ctx, appID, ruleID)
+	}
+	return control.IngressRule{}, nil
+}
+
+// CreateIngressRule implements control.ControlAPI.
+func (f *Fake) CreateIngressRule(ctx context.Context, appID string, rule *control.NewIngressRule) (control.IngressRule, error) {
+	f.record("CreateIngressRule", appID, rule)
+	if f.CreateIngressRuleFunc != nil {
+		return f.CreateIngressRuleFunc(ctx, appID, rule)
+	}
+	return control.IngressRule{}, nil
+}
+
+// UpdateIngressRule implements control.ControlAPI.
+func (f *Fake) UpdateIngressRule(ctx context.Context, appID, ruleID string, rule *control.NewIngressRule) (control.IngressRule, error) {
+	f.record("UpdateIngressRule", appID, ruleID, rule)
+	if f.UpdateIngressRuleFunc != nil {
+		return f.UpdateIngressRuleFunc(ctx, appID, ruleID, rule)
+	}
+	return control.IngressRule{}, nil
+}
+
+// DeleteIngressRule implements control.ControlAPI.
+func (f *Fake) DeleteIngressRule(ctx context.Context, appID, ruleID string) error {
+	f.record("DeleteIngressRule", appID, ruleID)
+	if f.DeleteIngressRuleFunc != nil {
+		return f.DeleteIngressRuleFunc(ctx, appID, ruleID)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package controlmock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// provision creates an app with a key, using only the interfaces it needs.
+func provision(ctx context.Context, api interface {
+	control.AppsAPI
+	control.KeysAPI
+}, name string) (control.Key, error) {
+	app, err := api.CreateApp(ctx, &control.NewApp{Name: name})
+	if err != nil {
+		return control.Key{}, err
+	}
+	return api.CreateKey(ctx, app.ID, &control.NewKey{Name: name})
+}
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := &Fake{
+		CreateAppFunc: func(ctx context.Context, app *control.NewApp) (control.App, error) {
+			return control.App{ID: "app", Name: app.Name}, nil
+		},
+	}
+
+	key, err := provision(ctx, fake, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, control.Key{}, key)
+
+	calls := fake.Calls()
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "CreateApp", calls[0].Method)
+	assert.Equal(t, []interface{}{&control.NewApp{Name: "test"}}, calls[0].Args)
+	assert.Equal(t, []Call{{Method: "CreateKey", Args: []interface{}{"app", &control.NewKey{Name: "test"}}}}, fake.CallsTo("CreateKey"))
+
+	fake.Reset()
+	assert.Empty(t, fake.Calls())
+}
+
+func TestFakeIterators(t *testing.T) {
+	ctx := context.Background()
+	fake := &Fake{
+		KeysFunc: func(ctx context.Context, appID string) ([]control.Key, error) {
+			return []control.Key{{ID: "a"}, {ID: "b"}}, nil
+		},
+		QueuesFunc: func(ctx context.Context, appID string) ([]control.Queue, error) {
+			return nil, errors.New("failed")
+		},
+	}
+
+	var ids []string
+	for key, err := range fake.AllKeys(ctx, "app") {
+		assert.NoError(t, err)
+		ids = append(ids, key.ID)
+	}
+	assert.Equal(t, []string{"a", "b"}, ids)
+
+	for _, err := range fake.AllQueues(ctx, "app") {
+		assert.EqualError(t, err, "failed")
+	}
+
+	for range fake.AllApps(ctx) {
+		t.Fatal("no apps expected")
+	}
+	assert.Equal(t, []Call{{Method: "AllKeys", Args: []interface{}{"app"}}}, fake.CallsTo("AllKeys"))
+	assert.Empty(t, fake.CallsTo("Keys"))
+}
@@ -0,0 +1,145 @@
+// Package controlotel instruments a control.Client with OpenTelemetry
+// tracing.
+//
+// Every call made by the Client creates a client span named after the
+// Client method, such as "CreateApp", and the trace context is propagated
+// to the Control API in the request headers:
+//
+//	client, _, err := control.NewClient(ctx, token,
+//		control.WithMiddleware(controlotel.Middleware()),
+//	)
+package controlotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	control "github.com/ably/ably-control-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name used for the tracer.
+const ScopeName = "github.com/ably/ably-control-go/controlotel"
+
+// Attribute keys set on spans.
+const (
+	// AccountIDKey is the ID of the account the Client manages.
+	AccountIDKey = attribute.Key("ably.account.id")
+	// AppIDKey is the ID of the app the call applies to.
+	AppIDKey = attribute.Key("ably.app.id")
+	// ResourceTypeKey is the type of resource the call applies to, such as
+	// "keys" or "rules".
+	ResourceTypeKey = attribute.Key("ably.resource.type")
+	// ResourceIDKey is the ID of the resource the call applies to.
+	ResourceIDKey = attribute.Key("ably.resource.id")
+	// ErrorCodeKey is the Ably error code of a failed call.
+	ErrorCodeKey = attribute.Key("ably.error.code")
+	// HTTPMethodKey is the HTTP method of the call.
+	HTTPMethodKey = attribute.Key("http.request.method")
+	// HTTPStatusCodeKey is the HTTP status code of the response.
+	HTTPStatusCodeKey = attribute.Key("http.response.status_code")
+)
+
+// Option configures the middleware.
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the TracerProvider used to create spans. It
+// defaults to the global TracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithPropagator sets the propagator used to inject the trace context into
+// requests. It defaults to the global TextMapPropagator.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = propagator
+	}
+}
+
+// Middleware returns control.Middleware which traces every call made by a
+// Client. It should be the outermost middleware, so that the span covers
+// any retries.
+func Middleware(opts ...Option) control.Middleware {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	tracer := c.tracerProvider.Tracer(ScopeName)
+
+	return func(next control.Doer) control.Doer {
+		return control.DoerFunc(func(ctx context.Context, req *control.Request) (*http.Response, error) {
+			ctx, span := tracer.Start(ctx, req.Operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(requestAttributes(req)...),
+			)
+			defer span.End()
+
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			c.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+			res, err := next.Do(ctx, req)
+			if res != nil {
+				span.SetAttributes(HTTPStatusCodeKey.Int(res.StatusCode))
+			}
+			if err != nil {
+				var errorInfo control.ErrorInfo
+				if errors.As(err, &errorInfo) {
+					span.SetAttributes(HTTPStatusCodeKey.Int(errorInfo.StatusCode))
+					if errorInfo.Code != 0 {
+						span.SetAttributes(ErrorCodeKey.Int(errorInfo.Code))
+					}
+				}
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return res, err
+		})
+	}
+}
+
+// requestAttributes returns the attributes describing a request, taking
+// the app and resource IDs from its path.
+func requestAttributes(req *control.Request) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{HTTPMethodKey.String(req.Method)}
+	accountID := req.AccountID
+
+	path, _, _ := strings.Cut(req.Path, "?")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(segments) >= 2 && segments[0] == "accounts":
+		// /accounts/{accountID}/apps
+		accountID = segments[1]
+	case len(segments) >= 2 && segments[0] == "apps":
+		// /apps/{appID}[/{resourceType}[/{resourceID}[/revoke]]]
+		attrs = append(attrs, AppIDKey.String(segments[1]))
+		if len(segments) >= 3 {
+			attrs = append(attrs, ResourceTypeKey.String(segments[2]))
+		}
+		if len(segments) >= 4 {
+			attrs = append(attrs, ResourceIDKey.String(segments[3]))
+		}
+	}
+	if accountID != "" {
+		attrs = append(attrs, AccountIDKey.String(accountID))
+	}
+	return attrs
+}
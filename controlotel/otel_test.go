@@ -0,0 +1,97 @@
+package controlotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	var traceparent string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case http.MethodPost:
+			traceparent = req.Header.Get("Traceparent")
+			w.Write([]byte(`{"id":"key1","appId":"app1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not found","code":40400,"statusCode":404}`))
+		}
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client, err := control.NewClientWithAccountID("s3cr3t", "acc1",
+		control.WithURL(srv.URL),
+		control.WithMiddleware(Middleware(
+			WithTracerProvider(provider),
+			WithPropagator(propagation.TraceContext{}),
+		)),
+	)
+	assert.NoError(t, err)
+
+	_, err = client.CreateKey(ctx, "app1", &control.NewKey{Name: "test"})
+	assert.NoError(t, err)
+	err = client.DeleteRule(ctx, "app1", "rule1")
+	assert.Error(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+
+	create := spans[0]
+	assert.Equal(t, "CreateKey", create.Name)
+	assert.Equal(t, trace.SpanKindClient, create.SpanKind)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		HTTPMethodKey.String("POST"),
+		AccountIDKey.String("acc1"),
+		AppIDKey.String("app1"),
+		ResourceTypeKey.String("keys"),
+		HTTPStatusCodeKey.Int(200),
+	}, create.Attributes)
+	assert.Contains(t, traceparent, create.SpanContext.TraceID().String())
+
+	del := spans[1]
+	assert.Equal(t, "DeleteRule", del.Name)
+	assert.Equal(t, codes.Error, del.Status.Code)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		HTTPMethodKey.String("DELETE"),
+		AccountIDKey.String("acc1"),
+		AppIDKey.String("app1"),
+		ResourceTypeKey.String("rules"),
+		ResourceIDKey.String("rule1"),
+		HTTPStatusCodeKey.Int(404),
+		ErrorCodeKey.Int(40400),
+	}, del.Attributes)
+}
+
+func TestRequestAttributes(t *testing.T) {
+	assert.Equal(t, []attribute.KeyValue{
+		HTTPMethodKey.String("GET"),
+		AccountIDKey.String("acc2"),
+	}, requestAttributes(&control.Request{Method: "GET", Path: "/accounts/acc2/apps?cursor=x", AccountID: "acc1"}))
+
+	assert.Equal(t, []attribute.KeyValue{
+		HTTPMethodKey.String("POST"),
+		AppIDKey.String("app"),
+		ResourceTypeKey.String("keys"),
+		ResourceIDKey.String("key"),
+	}, requestAttributes(&control.Request{Method: "POST", Path: "/apps/app/keys/key/revoke"}))
+
+	assert.Equal(t, []attribute.KeyValue{
+		HTTPMethodKey.String("GET"),
+	}, requestAttributes(&control.Request{Method: "GET", Path: "/me"}))
+}
@@ -0,0 +1,140 @@
+// Package controlprom records the calls made by a control.Client as
+// Prometheus metrics.
+//
+//	metrics, err := controlprom.New(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		return err
+//	}
+//	client, _, err := control.NewClient(ctx, token, control.WithMetrics(metrics))
+//
+// The following metrics are recorded, each labelled by the Client method
+// which made the call:
+//
+//   - ably_control_requests_total counts calls, labelled by HTTP method and
+//     status code.
+//   - ably_control_request_duration_seconds is a histogram of the time taken
+//     by calls, including retries.
+//   - ably_control_retries_total counts retried requests.
+//   - ably_control_errors_total counts failed calls, labelled by HTTP status
+//     code and Ably error code.
+package controlprom
+
+import (
+	"context"
+	"strconv"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures Metrics.
+type Option func(*config)
+
+type config struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// WithNamespace sets the namespace prefixed to metric names. It defaults to
+// "ably".
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric, for
+// example to tell apart several Clients.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the request duration histogram, in
+// seconds. It defaults to prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// Metrics implements control.Metrics by recording Prometheus metrics.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	retries  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+var _ control.Metrics = (*Metrics)(nil)
+
+// New creates Metrics and registers them with registerer. If any of them
+// can't be registered, none are left registered.
+func New(registerer prometheus.Registerer, opts ...Option) (*Metrics, error) {
+	c := config{
+		namespace: "ably",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "control",
+			Name:        "requests_total",
+			Help:        "Number of calls made to the Ably Control API.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "control",
+			Name:        "request_duration_seconds",
+			Help:        "Time taken by calls to the Ably Control API, including retries.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.buckets,
+		}, []string{"operation"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "control",
+			Name:        "retries_total",
+			Help:        "Number of requests to the Ably Control API which were retried.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "control",
+			Name:        "errors_total",
+			Help:        "Number of calls to the Ably Control API which failed.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "status", "code"}),
+	}
+	collectors := []prometheus.Collector{m.requests, m.duration, m.retries, m.errors}
+	for i, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			// unregister the metrics already registered, so New can be
+			// called again once the conflict is resolved.
+			for _, registered := range collectors[:i] {
+				registerer.Unregister(registered)
+			}
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveRequest records a call to the Control API.
+func (m *Metrics) ObserveRequest(ctx context.Context, o control.RequestObservation) {
+	status := strconv.Itoa(o.StatusCode)
+	m.requests.WithLabelValues(o.Operation, o.Method, status).Inc()
+	m.duration.WithLabelValues(o.Operation).Observe(o.Duration.Seconds())
+	if retries := o.Retries(); retries > 0 {
+		m.retries.WithLabelValues(o.Operation).Add(float64(retries))
+	}
+	if o.Err != nil {
+		m.errors.WithLabelValues(o.Operation, status, strconv.Itoa(o.Code)).Inc()
+	}
+}
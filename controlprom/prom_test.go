@@ -0,0 +1,89 @@
+package controlprom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	var requests int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case req.Method == http.MethodGet && requests == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"unavailable","code":50300,"statusCode":503}`))
+		case req.Method == http.MethodGet:
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not found","code":40400,"statusCode":404}`))
+		}
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	registry := prometheus.NewPedanticRegistry()
+	metrics, err := New(registry)
+	assert.NoError(t, err)
+
+	client, err := control.NewClientWithAccountID("token", "acc",
+		control.WithURL(srv.URL),
+		control.WithMetrics(metrics),
+		control.WithRetryPolicy(control.RetryPolicy{MaxAttempts: 2}),
+	)
+	assert.NoError(t, err)
+
+	_, err = client.Keys(ctx, "app")
+	assert.NoError(t, err)
+	err = client.DeleteQueue(ctx, "app", "queue")
+	assert.Error(t, err)
+
+	expected := `
+# HELP ably_control_errors_total Number of calls to the Ably Control API which failed.
+# TYPE ably_control_errors_total counter
+ably_control_errors_total{code="40400",operation="DeleteQueue",status="404"} 1
+# HELP ably_control_requests_total Number of calls made to the Ably Control API.
+# TYPE ably_control_requests_total counter
+ably_control_requests_total{method="DELETE",operation="DeleteQueue",status="404"} 1
+ably_control_requests_total{method="GET",operation="Keys",status="200"} 1
+# HELP ably_control_retries_total Number of requests to the Ably Control API which were retried.
+# TYPE ably_control_retries_total counter
+ably_control_retries_total{operation="Keys"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"ably_control_errors_total", "ably_control_requests_total", "ably_control_retries_total"))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.duration))
+
+	_, err = New(registry)
+	assert.Error(t, err)
+}
+
+// TestNewUnregistersOnError tests that New leaves no metrics registered
+// when one of them can't be registered.
+func TestNewUnregistersOnError(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	conflict := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ably_control_retries_total",
+		Help: "Number of requests to the Ably Control API which were retried.",
+	}, []string{"operation"})
+	assert.NoError(t, registry.Register(conflict))
+
+	_, err := New(registry)
+	assert.Error(t, err)
+
+	// once the conflict is removed, New registers every metric.
+	assert.True(t, registry.Unregister(conflict))
+	_, err = New(registry)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,106 @@
+package controltest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// appWriteOnly are the app fields which can be set but are not returned.
+var appWriteOnly = []string{"apnsCertificate", "apnsPrivateKey"}
+
+func (s *Server) checkAccount(r *http.Request) *Error {
+	if id := r.PathValue("accountID"); id != s.AccountID {
+		return errNotFound("Account not found: " + id)
+	}
+	return nil
+}
+
+func validateApp(v validation, body object) {
+	v.oneOf(body, "status", "enabled", "disabled")
+}
+
+func (s *Server) listApps(r *http.Request) (int, interface{}) {
+	if err := s.checkAccount(r); err != nil {
+		return 0, err
+	}
+	apps := make([]object, 0, len(s.apps))
+	for _, a := range s.apps {
+		apps = append(apps, a.fields)
+	}
+	return http.StatusOK, apps
+}
+
+func (s *Server) createApp(r *http.Request) (int, interface{}) {
+	if err := s.checkAccount(r); err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	v.required(body, "name")
+	validateApp(v, body)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	if s.appNamed(body["name"], nil) {
+		return 0, errConflict(fmt.Sprint("App name already in use: ", body["name"]))
+	}
+	fields := object{
+		"status":                 "enabled",
+		"tlsOnly":                false,
+		"fcmKey":                 nil,
+		"fcmServiceAccount":      nil,
+		"fcmProjectId":           nil,
+		"apnsUseSandboxEndpoint": false,
+	}
+	merge(fields, body, append(appWriteOnly, "id", "accountId")...)
+	fields["id"] = randomID(6)
+	fields["accountId"] = s.AccountID
+	s.apps = append(s.apps, &app{fields: fields})
+	return http.StatusCreated, fields
+}
+
+// appNamed reports whether an app other than except has the given name.
+func (s *Server) appNamed(name interface{}, except *app) bool {
+	return slices.ContainsFunc(s.apps, func(a *app) bool {
+		return a != except && a.fields["name"] == name
+	})
+}
+
+func (s *Server) updateApp(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	if _, ok := body["name"]; ok {
+		v.required(body, "name")
+	}
+	validateApp(v, body)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	if name, ok := body["name"]; ok && s.appNamed(name, a) {
+		return 0, errConflict(fmt.Sprint("App name already in use: ", name))
+	}
+	merge(a.fields, body, append(appWriteOnly, "id", "accountId")...)
+	return http.StatusOK, a.fields
+}
+
+func (s *Server) deleteApp(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	s.apps = slices.DeleteFunc(s.apps, func(other *app) bool {
+		return other == a
+	})
+	return http.StatusNoContent, nil
+}
@@ -0,0 +1,369 @@
+package controltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ably/ably-control-go/internal/redact"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode selects whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeReplay answers requests with the interactions in the cassette,
+	// without sending them.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests and records the interactions, replacing
+	// the cassette when Save is called.
+	ModeRecord
+)
+
+// Cassette is a recording of interactions with the Control API.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it received.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request. Secrets in its headers and body
+// are redacted.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	// The JSON body decoded into an interface{}.
+	Body interface{} `json:"body,omitempty"`
+	// The body if it is not JSON.
+	Text string `json:"text,omitempty"`
+}
+
+// RecordedResponse is a recorded response. Secrets in its body are
+// redacted, and only headers needed to replay it are recorded.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	// The JSON body decoded into an interface{}.
+	Body interface{} `json:"body,omitempty"`
+	// The body if it is not JSON.
+	Text string `json:"text,omitempty"`
+}
+
+// cassetteVersion is the version of the cassette format.
+const cassetteVersion = 1
+
+// recordedRequestHeaders are the request headers which are recorded.
+var recordedRequestHeaders = []string{"Authorization", "Content-Type", "Idempotency-Key"}
+
+// recordedResponseHeaders are the response headers which are recorded.
+// Others, such as Set-Cookie, may carry credentials.
+var recordedResponseHeaders = []string{"Content-Type", "Link", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// Recorder is an http.RoundTripper which records interactions with the
+// Control API to a cassette file, and replays them, for use with
+// control.WithTransport.
+//
+//	rec, err := controltest.NewRecorder("testdata/kafka.yaml", controltest.ModeReplay, nil)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client, err := control.NewClientWithAccountID("token", accountID, control.WithTransport(rec))
+//
+// Cassettes are JSON, or YAML if the file name ends in ".yaml" or ".yml".
+// Access tokens and secret fields, such as key secrets and passwords, are
+// redacted before they are recorded.
+//
+// A request is answered by the first interaction not yet replayed whose
+// method, path and body match it, so identical requests are answered in the
+// order they were recorded. A request which matches no interaction fails
+// with an error describing it.
+type Recorder struct {
+	path string
+	mode Mode
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	replayed []bool
+}
+
+// NewRecorder creates a Recorder for the cassette at path. In ModeReplay the
+// cassette is loaded and must exist. In ModeRecord requests are sent with
+// base, or with http.DefaultTransport if base is nil.
+func NewRecorder(path string, mode Mode, base http.RoundTripper) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r := &Recorder{
+		path:     path,
+		mode:     mode,
+		base:     base,
+		cassette: Cassette{Version: cassetteVersion},
+	}
+	if mode == ModeReplay {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// load reads the cassette.
+func (r *Recorder) load() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("controltest: reading cassette: %w", err)
+	}
+	if isYAML(r.path) {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("controltest: reading cassette %s: %w", r.path, err)
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return fmt.Errorf("controltest: reading cassette %s: %w", r.path, err)
+		}
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return fmt.Errorf("controltest: reading cassette %s: %w", r.path, err)
+	}
+	if r.cassette.Version != cassetteVersion {
+		return fmt.Errorf("controltest: cassette %s has unsupported version %d", r.path, r.cassette.Version)
+	}
+	r.replayed = make([]bool, len(r.cassette.Interactions))
+	return nil
+}
+
+// Save writes the recorded interactions to the cassette file. It does
+// nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if isYAML(r.path) {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// Remaining returns the number of interactions which have not been replayed.
+func (r *Recorder) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, replayed := range r.replayed {
+		if !replayed {
+			n++
+		}
+	}
+	return n
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := recordRequest(req, body)
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	res, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{Request: recorded, Response: RecordedResponse{
+		StatusCode: res.StatusCode,
+		Header:     recordHeader(res.Header, recordedResponseHeaders),
+	}}
+	interaction.Response.Body, interaction.Response.Text = recordBody(resBody)
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+	return res, nil
+}
+
+// replay answers a request with the first matching interaction.
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.replayed[i] || !interaction.Request.matches(recorded) {
+			continue
+		}
+		r.replayed[i] = true
+		return interaction.Response.response(req)
+	}
+	return nil, &UnexpectedRequestError{Cassette: r.path, Request: recorded}
+}
+
+// UnexpectedRequestError is returned for a request which matches no
+// interaction in a cassette being replayed.
+type UnexpectedRequestError struct {
+	Cassette string
+	Request  RecordedRequest
+}
+
+func (e *UnexpectedRequestError) Error() string {
+	msg := fmt.Sprintf("controltest: cassette %s has no interaction for %s %s", e.Cassette, e.Request.Method, e.Request.Path)
+	if e.Request.Body != nil {
+		body, _ := json.Marshal(e.Request.Body)
+		msg += " with body " + string(body)
+	} else if e.Request.Text != "" {
+		msg += " with body " + e.Request.Text
+	}
+	return msg
+}
+
+// IsUnexpectedRequest reports whether err is caused by a request which
+// matched no recorded interaction.
+func IsUnexpectedRequest(err error) bool {
+	var unexpected *UnexpectedRequestError
+	return errors.As(err, &unexpected)
+}
+
+// recordRequest returns the recorded form of a request.
+func recordRequest(req *http.Request, body []byte) RecordedRequest {
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+	}
+	recorded.Header = recordHeader(req.Header, recordedRequestHeaders)
+	if auth := recorded.Header.Get("Authorization"); auth != "" {
+		recorded.Header.Set("Authorization", redact.Authorization(auth))
+	}
+	recorded.Body, recorded.Text = recordBody(body)
+	return recorded
+}
+
+// recordHeader returns the headers in names which are set in header, or nil
+// if there are none.
+func recordHeader(header http.Header, names []string) http.Header {
+	var recorded http.Header
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if recorded == nil {
+			recorded = http.Header{}
+		}
+		recorded[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+	}
+	return recorded
+}
+
+// recordBody returns a body decoded from JSON with secrets redacted, or as
+// text if it is not JSON.
+func recordBody(body []byte) (interface{}, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, string(body)
+	}
+	return redact.Value(v), ""
+}
+
+// matches reports whether a request matches the recorded one by method,
+// path and body.
+func (r RecordedRequest) matches(other RecordedRequest) bool {
+	return r.Method == other.Method &&
+		r.Path == other.Path &&
+		r.Text == other.Text &&
+		reflect.DeepEqual(normalize(r.Body), normalize(other.Body))
+}
+
+// normalize makes a value decoded from YAML comparable with one decoded from
+// JSON.
+func normalize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// response returns the recorded response as an *http.Response.
+func (r RecordedResponse) response(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Text)
+	if r.Body != nil {
+		var err error
+		if body, err = json.Marshal(r.Body); err != nil {
+			return nil, err
+		}
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// isYAML reports whether a cassette file is YAML.
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
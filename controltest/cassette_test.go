@@ -0,0 +1,142 @@
+package controltest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/ably/ably-control-go/controltest"
+	"github.com/stretchr/testify/assert"
+)
+
+// exercise makes the calls which are recorded and replayed.
+func exercise(t *testing.T, client control.Client) (control.Key, []control.Rule) {
+	ctx := context.Background()
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "cassette"})
+	assert.NoError(t, err)
+	key, err := client.CreateKey(ctx, app.ID, &control.NewKey{
+		Name:       "key",
+		Capability: map[string][]string{"*": {"publish"}},
+	})
+	assert.NoError(t, err)
+	_, err = client.CreateRule(ctx, app.ID, &control.NewRule{
+		Source: control.Source{ChannelFilter: "^a", Type: control.ChannelMessage},
+		Target: &control.KafkaTarget{
+			RoutingKey: "topic:key",
+			Brokers:    []string{"kafka.example.com:9092"},
+			Authentication: control.KafkaAuthentication{
+				Sasl: control.Sasl{Mechanism: control.Plain, Username: "user", Password: "hunter2"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	rules, err := client.Rules(ctx, app.ID)
+	assert.NoError(t, err)
+	return key, rules
+}
+
+func TestRecorder(t *testing.T) {
+	for _, name := range []string{"cassette.json", "cassette.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "testdata", name)
+
+			srv := controltest.NewServer(controltest.WithAccountID("acc"))
+			rec, err := controltest.NewRecorder(path, controltest.ModeRecord, nil)
+			assert.NoError(t, err)
+			client, err := control.NewClientWithAccountID(srv.Token, "acc", control.WithURL(srv.URL), control.WithTransport(rec))
+			assert.NoError(t, err)
+			key, rules := exercise(t, client)
+			assert.NoError(t, rec.Save())
+			srv.Close()
+
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NotContains(t, string(data), srv.Token)
+			assert.NotContains(t, string(data), key.Key)
+			assert.NotContains(t, string(data), "hunter2")
+			assert.Contains(t, string(data), "Bearer [REDACTED]")
+
+			rec, err = controltest.NewRecorder(path, controltest.ModeReplay, nil)
+			assert.NoError(t, err)
+			client, err = control.NewClientWithAccountID("other-token", "acc", control.WithURL(srv.URL), control.WithTransport(rec))
+			assert.NoError(t, err)
+			replayedKey, replayedRules := exercise(t, client)
+			assert.Equal(t, key.ID, replayedKey.ID)
+			assert.Equal(t, "[REDACTED]", replayedKey.Key)
+			assert.Len(t, replayedRules, 1)
+			assert.Equal(t, rules[0].ID, replayedRules[0].ID)
+			assert.Equal(t, 0, rec.Remaining())
+
+			// every interaction has been replayed.
+			_, err = client.Apps(ctx)
+			assert.True(t, controltest.IsUnexpectedRequest(err))
+			assert.ErrorContains(t, err, "has no interaction for GET /accounts/acc/apps")
+		})
+	}
+}
+
+func TestRecorderUnexpectedBody(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	srv := controltest.NewServer()
+	defer srv.Close()
+	rec, err := controltest.NewRecorder(path, controltest.ModeRecord, nil)
+	assert.NoError(t, err)
+	client, err := control.NewClientWithAccountID(srv.Token, srv.AccountID, control.WithURL(srv.URL), control.WithTransport(rec))
+	assert.NoError(t, err)
+	_, err = client.CreateApp(ctx, &control.NewApp{Name: "one"})
+	assert.NoError(t, err)
+	assert.NoError(t, rec.Save())
+
+	rec, err = controltest.NewRecorder(path, controltest.ModeReplay, nil)
+	assert.NoError(t, err)
+	client, err = control.NewClientWithAccountID(srv.Token, srv.AccountID, control.WithURL(srv.URL), control.WithTransport(rec))
+	assert.NoError(t, err)
+	_, err = client.CreateApp(ctx, &control.NewApp{Name: "two"})
+	assert.True(t, controltest.IsUnexpectedRequest(err))
+	assert.ErrorContains(t, err, `"name":"two"`)
+	assert.Equal(t, 1, rec.Remaining())
+
+	_, err = controltest.NewRecorder(filepath.Join(t.TempDir(), "missing.json"), controltest.ModeReplay, nil)
+	assert.Error(t, err)
+}
+
+func TestRecorderResponseHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Link", `</apps?page=2>; rel="next"`)
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.Header().Set("X-Upstream-Token", "s3cr3t")
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := controltest.NewRecorder(path, controltest.ModeRecord, nil)
+	assert.NoError(t, err)
+	res, err := (&http.Client{Transport: rec}).Get(srv.URL + "/apps")
+	assert.NoError(t, err)
+	res.Body.Close()
+	// the caller still gets every header.
+	assert.Equal(t, "session=s3cr3t", res.Header.Get("Set-Cookie"))
+	assert.NoError(t, rec.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cr3t")
+
+	rec, err = controltest.NewRecorder(path, controltest.ModeReplay, nil)
+	assert.NoError(t, err)
+	res, err = (&http.Client{Transport: rec}).Get(srv.URL + "/apps")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.Header{
+		"Content-Type": {"application/json"},
+		"Link":         {`</apps?page=2>; rel="next"`},
+	}, res.Header)
+}
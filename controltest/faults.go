@@ -0,0 +1,256 @@
+package controltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Fault fails a request instead of, or as well as, sending it with next.
+type Fault func(req *http.Request, next http.RoundTripper) (*http.Response, error)
+
+// RateLimited responds with a 429 and a Retry-After header.
+func RateLimited(retryAfter time.Duration) Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		res := errorResponse(req, &Error{StatusCode: http.StatusTooManyRequests, Code: 42910, Message: "Rate limit exceeded"})
+		res.Header.Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+		return res, nil
+	}
+}
+
+// ServerError responds with the given status code, such as 500 or 503, and
+// an error body with the matching Ably error code.
+func ServerError(statusCode int) Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		return errorResponse(req, &Error{StatusCode: statusCode, Code: statusCode * 100, Message: http.StatusText(statusCode)}), nil
+	}
+}
+
+// MalformedJSON responds with the given status code and a body which is
+// not valid JSON.
+func MalformedJSON(statusCode int) Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		return response(req, statusCode, "application/json", `{"message":"Internal error","code":`), nil
+	}
+}
+
+// HTMLError responds with the given status code and an HTML body, as a
+// proxy or load balancer in front of the Control API might.
+func HTMLError(statusCode int) Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		text := fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode))
+		body := "<html><head><title>" + text + "</title></head><body><h1>" + text + "</h1></body></html>"
+		return response(req, statusCode, "text/html", body), nil
+	}
+}
+
+// Truncated sends the request, and then cuts the response body off half
+// way through, as though the connection was lost while reading it.
+func Truncated() Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = io.NopCloser(io.MultiReader(
+			bytes.NewReader(body[:len(body)/2]),
+			errReader{io.ErrUnexpectedEOF},
+		))
+		res.ContentLength = -1
+		return res, nil
+	}
+}
+
+// Latency delays the request by d before sending it. The delay ends early
+// if the request's context is done.
+func Latency(d time.Duration) Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return next.RoundTrip(req)
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// ConnectionReset fails the request with a connection reset, without
+// sending it.
+func ConnectionReset() Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		return nil, connectionReset()
+	}
+}
+
+// ResponseLost sends the request, and then fails it with a connection
+// reset, as though the response was lost after the Control API applied
+// the change.
+func ResponseLost() Fault {
+	return func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		return nil, connectionReset()
+	}
+}
+
+func connectionReset() error {
+	return &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+}
+
+// response returns a response with the given status code and body.
+func response(req *http.Request, statusCode int, contentType, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// errorResponse returns a response with an error body.
+func errorResponse(req *http.Request, err *Error) *http.Response {
+	err.HRef = href(err.Code)
+	body, _ := json.Marshal(err)
+	return response(req, err.StatusCode, "application/json", string(body))
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// FaultRule selects the requests a Fault is injected into.
+type FaultRule struct {
+	// The HTTP method to match, or empty to match any method.
+	Method string
+	// A pattern matched against the URL path with path.Match, such as
+	// "/apps/*/keys", or empty to match any path. Paths of the real Control
+	// API start with "/v1".
+	Path string
+	// The number of requests to fail, or 0 to fail every matching request.
+	Times int
+	// The fault to inject.
+	Fault Fault
+}
+
+// matches reports whether the rule applies to req.
+func (r *FaultRule) matches(req *http.Request) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, req.URL.Path); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Transport is an http.RoundTripper which injects faults into requests, for
+// use with control.WithTransport.
+//
+//	faults := controltest.NewTransport(nil)
+//	faults.Inject(controltest.FaultRule{
+//		Method: "POST",
+//		Path:   "/apps/*/keys",
+//		Times:  1,
+//		Fault:  controltest.ResponseLost(),
+//	})
+//	client, _, err := control.NewClientWithURL(ctx, srv.Token, srv.URL, control.WithTransport(faults))
+//
+// Rules are checked in the order they were injected, and the first which
+// matches a request is applied. Requests which match no rule are sent
+// unchanged. It is safe to inject rules while requests are being made.
+type Transport struct {
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	rules    []*FaultRule
+	injected int
+}
+
+// NewTransport creates a Transport which sends requests with base, or with
+// http.DefaultTransport if base is nil.
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base}
+}
+
+// Inject adds a rule.
+func (t *Transport) Inject(rule FaultRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, &rule)
+}
+
+// Reset removes all rules.
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = nil
+}
+
+// Injected returns the number of faults injected so far.
+func (t *Transport) Injected() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.injected
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.match(req)
+	if fault == nil {
+		return t.base.RoundTrip(req)
+	}
+	return fault(req, t.base)
+}
+
+// match returns the fault to inject into req, or nil.
+func (t *Transport) match(req *http.Request) Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, rule := range t.rules {
+		if !rule.matches(req) {
+			continue
+		}
+		if rule.Times > 0 {
+			rule.Times--
+			if rule.Times == 0 {
+				t.rules = slices.Delete(t.rules, i, i+1)
+			}
+		}
+		t.injected++
+		return rule.Fault
+	}
+	return nil
+}
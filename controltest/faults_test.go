@@ -0,0 +1,125 @@
+package controltest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/ably/ably-control-go/controltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFaultyClient(t *testing.T, opts ...control.Option) (*controltest.Transport, control.Client, control.App) {
+	ctx := context.Background()
+	srv := controltest.NewServer()
+	t.Cleanup(srv.Close)
+	faults := controltest.NewTransport(nil)
+	opts = append([]control.Option{control.WithTransport(faults)}, opts...)
+	client, _, err := control.NewClientWithURL(ctx, srv.Token, srv.URL, opts...)
+	assert.NoError(t, err)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+	return faults, client, app
+}
+
+func TestFaultRateLimited(t *testing.T) {
+	ctx := context.Background()
+	faults, client, app := newFaultyClient(t, control.WithRetryPolicy(control.RetryPolicy{MaxAttempts: 3}))
+	faults.Inject(controltest.FaultRule{Method: "GET", Path: "/apps/*/keys", Times: 2, Fault: controltest.RateLimited(0)})
+
+	_, err := client.Keys(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, faults.Injected())
+
+	// the rule is used up.
+	_, err = client.Keys(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, faults.Injected())
+}
+
+func TestFaultServerError(t *testing.T) {
+	ctx := context.Background()
+	faults, client, app := newFaultyClient(t)
+	faults.Inject(controltest.FaultRule{Path: "/apps/*/queues", Fault: controltest.ServerError(http.StatusInternalServerError)})
+
+	_, err := client.Queues(ctx, app.ID)
+	var errorInfo control.ErrorInfo
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, control.CodeInternalError, errorInfo.Code)
+	assert.True(t, errorInfo.IsRetryable())
+
+	// other paths are unaffected.
+	_, err = client.Namespaces(ctx, app.ID)
+	assert.NoError(t, err)
+
+	faults.Reset()
+	_, err = client.Queues(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestFaultBodies(t *testing.T) {
+	ctx := context.Background()
+	faults, client, app := newFaultyClient(t)
+
+	faults.Inject(controltest.FaultRule{Times: 1, Fault: controltest.HTMLError(http.StatusBadGateway)})
+	_, err := client.Keys(ctx, app.ID)
+	var errorInfo control.ErrorInfo
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, http.StatusBadGateway, errorInfo.StatusCode)
+	assert.Contains(t, errorInfo.Message, "<h1>502 Bad Gateway</h1>")
+
+	faults.Inject(controltest.FaultRule{Times: 1, Fault: controltest.MalformedJSON(http.StatusInternalServerError)})
+	_, err = client.Keys(ctx, app.ID)
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, http.StatusInternalServerError, errorInfo.StatusCode)
+	assert.Equal(t, `{"message":"Internal error","code":`, errorInfo.Message)
+
+	faults.Inject(controltest.FaultRule{Times: 1, Fault: controltest.Truncated()})
+	_, err = client.Keys(ctx, app.ID)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestFaultLatency(t *testing.T) {
+	ctx := context.Background()
+	faults, client, app := newFaultyClient(t, control.WithTimeout(10*time.Millisecond))
+	faults.Inject(controltest.FaultRule{Fault: controltest.Latency(time.Second)})
+
+	start := time.Now()
+	_, err := client.Keys(ctx, app.ID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestFaultConnectionReset(t *testing.T) {
+	ctx := context.Background()
+	faults, client, app := newFaultyClient(t)
+	faults.Inject(controltest.FaultRule{Method: "DELETE", Fault: controltest.ConnectionReset()})
+
+	err := client.DeleteApp(ctx, app.ID)
+	var transportErr *control.TransportError
+	assert.ErrorAs(t, err, &transportErr)
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+
+	apps, err := client.Apps(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, apps, 1)
+}
+
+func TestFaultResponseLost(t *testing.T) {
+	ctx := context.Background()
+	faults, client, app := newFaultyClient(t, control.WithRetryPolicy(control.RetryPolicy{MaxAttempts: 3}))
+	faults.Inject(controltest.FaultRule{Method: "POST", Path: "/apps/*/keys", Times: 1, Fault: controltest.ResponseLost()})
+
+	key, err := client.CreateKey(ctx, app.ID, &control.NewKey{Name: "test", Capability: map[string][]string{"*": {"subscribe"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "test", key.Name)
+
+	keys, err := client.Keys(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
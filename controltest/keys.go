@@ -0,0 +1,140 @@
+package controltest
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// capabilityOperations are the operations a key's capability may grant.
+var capabilityOperations = []string{
+	"*",
+	"publish",
+	"subscribe",
+	"presence",
+	"history",
+	"stats",
+	"channel-metadata",
+	"push-subscribe",
+	"push-admin",
+	"privileged-headers",
+}
+
+func validateKey(v validation, body object) {
+	capability, ok := body["capability"]
+	if !ok || capability == nil {
+		v.add("capability", "is required")
+		return
+	}
+	resources, ok := capability.(object)
+	if !ok || len(resources) == 0 {
+		v.add("capability", "must grant at least one operation")
+		return
+	}
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		operations, ok := resources[name].([]interface{})
+		if !ok || len(operations) == 0 {
+			v.add("capability."+name, "must grant at least one operation")
+			continue
+		}
+		for _, op := range operations {
+			if s, ok := op.(string); !ok || !slices.Contains(capabilityOperations, s) {
+				v.add("capability."+name, "invalid operation %v", op)
+			}
+		}
+	}
+}
+
+func (s *Server) listKeys(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	return http.StatusOK, a.keys
+}
+
+func (s *Server) createKey(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	validateKey(v, body)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	appID := a.fields["id"].(string)
+	id := randomID(6)
+	created := now()
+	key := object{
+		"id":              id,
+		"appId":           appID,
+		"name":            body["name"],
+		"status":          0,
+		"key":             appID + "." + id + ":" + randomID(43),
+		"capability":      body["capability"],
+		"created":         created,
+		"modified":        created,
+		"revocableTokens": body["revocableTokens"] == true,
+	}
+	a.keys = append(a.keys, key)
+	return http.StatusCreated, key
+}
+
+// key returns the key with the ID in the request path.
+func (s *Server) key(r *http.Request) (object, *Error) {
+	a, err := s.app(r)
+	if err != nil {
+		return nil, err
+	}
+	id := r.PathValue("keyID")
+	i := indexByID(a.keys, id)
+	if i < 0 {
+		return nil, errNotFound("Key not found: " + id)
+	}
+	return a.keys[i], nil
+}
+
+func (s *Server) updateKey(r *http.Request) (int, interface{}) {
+	key, err := s.key(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	if _, ok := body["capability"]; ok {
+		validateKey(v, body)
+	}
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	for _, name := range []string{"name", "capability", "revocableTokens"} {
+		if value, ok := body[name]; ok {
+			key[name] = value
+		}
+	}
+	key["modified"] = now()
+	return http.StatusOK, key
+}
+
+func (s *Server) revokeKey(r *http.Request) (int, interface{}) {
+	key, err := s.key(r)
+	if err != nil {
+		return 0, err
+	}
+	key["status"] = 1
+	key["modified"] = now()
+	return http.StatusOK, nil
+}
@@ -0,0 +1,118 @@
+package controltest
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+)
+
+var namespaceID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func validateNamespace(v validation, namespace object) {
+	if enabled, _ := namespace["batchingEnabled"].(bool); enabled {
+		v.between(namespace, "batchingInterval", 20, 1000)
+	}
+	if enabled, _ := namespace["conflationEnabled"].(bool); enabled {
+		v.between(namespace, "conflationInterval", 20, 10000)
+	}
+}
+
+// normalizeNamespace clears the settings of features which are disabled.
+func normalizeNamespace(namespace object) {
+	if enabled, _ := namespace["batchingEnabled"].(bool); !enabled {
+		delete(namespace, "batchingInterval")
+	}
+	if enabled, _ := namespace["conflationEnabled"].(bool); !enabled {
+		namespace["conflationInterval"] = nil
+		namespace["conflationKey"] = ""
+	}
+}
+
+func (s *Server) listNamespaces(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	return http.StatusOK, a.namespaces
+}
+
+func (s *Server) createNamespace(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	v.required(body, "id")
+	if id, ok := body["id"].(string); ok && id != "" && !namespaceID.MatchString(id) {
+		v.add("id", "must only contain letters, numbers, underscores and hyphens")
+	}
+	validateNamespace(v, body)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	id := body["id"].(string)
+	if indexByID(a.namespaces, id) >= 0 {
+		return 0, errConflict("Namespace already exists: " + id)
+	}
+	namespace := object{
+		"authenticated":      false,
+		"persisted":          false,
+		"persistLast":        false,
+		"pushEnabled":        false,
+		"tlsOnly":            false,
+		"exposeTimeserial":   false,
+		"batchingEnabled":    false,
+		"conflationEnabled":  false,
+		"conflationInterval": nil,
+		"conflationKey":      "",
+	}
+	merge(namespace, body)
+	normalizeNamespace(namespace)
+	a.namespaces = append(a.namespaces, namespace)
+	return http.StatusCreated, namespace
+}
+
+func (s *Server) updateNamespace(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	id := r.PathValue("namespaceID")
+	i := indexByID(a.namespaces, id)
+	if i < 0 {
+		return 0, errNotFound("Namespace not found: " + id)
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	namespace := object{}
+	merge(namespace, a.namespaces[i])
+	merge(namespace, body, "id")
+	v := validation{}
+	validateNamespace(v, namespace)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	normalizeNamespace(namespace)
+	a.namespaces[i] = namespace
+	return http.StatusOK, namespace
+}
+
+func (s *Server) deleteNamespace(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	id := r.PathValue("namespaceID")
+	i := indexByID(a.namespaces, id)
+	if i < 0 {
+		return 0, errNotFound("Namespace not found: " + id)
+	}
+	a.namespaces = slices.Delete(a.namespaces, i, i+1)
+	return http.StatusNoContent, nil
+}
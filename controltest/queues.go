@@ -0,0 +1,101 @@
+package controltest
+
+import (
+	"net/http"
+	"slices"
+)
+
+// queueHosts are the hosts serving queues in each region.
+var queueHosts = map[string]string{
+	"us-east-1-a": "us-east-1-a-queue.ably.io",
+	"eu-west-1-a": "eu-west-1-a-queue.ably.io",
+}
+
+func (s *Server) listQueues(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	return http.StatusOK, a.queues
+}
+
+func (s *Server) createQueue(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	v.required(body, "name")
+	v.required(body, "region")
+	v.oneOf(body, "region", "us-east-1-a", "eu-west-1-a")
+	v.between(body, "ttl", 1, 60)
+	v.between(body, "maxLength", 1, 10000)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	appID := a.fields["id"].(string)
+	name := body["name"].(string)
+	region := body["region"].(string)
+	if indexByID(a.queues, queueID(appID, region, name)) >= 0 {
+		return 0, errConflict("Queue already exists: " + name)
+	}
+	queue := newQueue(appID, region, name, body["ttl"], body["maxLength"], false)
+	a.queues = append(a.queues, queue)
+	// like the Control API, create the dead letter queue for the region
+	// along with the first queue in it.
+	if indexByID(a.queues, queueID(appID, region, deadLetterName)) < 0 {
+		a.queues = append(a.queues, newQueue(appID, region, deadLetterName, 60, 10000, true))
+	}
+	return http.StatusCreated, queue
+}
+
+// deadLetterName is the name of the dead letter queue in each region.
+const deadLetterName = "deadletter"
+
+func queueID(appID, region, name string) string {
+	return appID + ":" + region + ":" + name
+}
+
+// newQueue returns a new, empty queue.
+func newQueue(appID, region, name string, ttl, maxLength interface{}, deadLetter bool) object {
+	host := queueHosts[region]
+	return object{
+		"id":     queueID(appID, region, name),
+		"appId":  appID,
+		"name":   name,
+		"region": region,
+		"amqp": object{
+			"uri":       "amqps://" + host + ":5671/shared",
+			"queueName": appID + ":" + name,
+		},
+		"stomp": object{
+			"uri":         "stomp://" + host,
+			"host":        "shared",
+			"destination": "/amqp/queue/" + appID + ":" + name,
+		},
+		"state":      "Running",
+		"messages":   object{"ready": 0, "unacknowledged": 0, "total": 0},
+		"stats":      object{"publishRate": nil, "deliveryRate": nil, "acknowledgementRate": nil},
+		"ttl":        ttl,
+		"maxLength":  maxLength,
+		"deadLetter": deadLetter,
+	}
+}
+
+func (s *Server) deleteQueue(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	id := r.PathValue("queueID")
+	i := indexByID(a.queues, id)
+	if i < 0 {
+		return 0, errNotFound("Queue not found: " + id)
+	}
+	a.queues = slices.Delete(a.queues, i, i+1)
+	return http.StatusNoContent, nil
+}
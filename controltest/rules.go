@@ -0,0 +1,160 @@
+package controltest
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ruleTargets are the target fields which are required for each rule type.
+var ruleTargets = map[string][]string{
+	"pulsar":                     {"topic", "serviceUrl"},
+	"kafka":                      {"brokers"},
+	"amqp/external":              {"url"},
+	"amqp":                       {"queueId"},
+	"aws/sqs":                    {"region", "awsAccountId", "queueName"},
+	"aws/kinesis":                {"region", "streamName"},
+	"aws/lambda":                 {"region", "functionName"},
+	"http/google-cloud-function": {"region", "projectId", "functionName"},
+	"http/azure-function":        {"azureAppId", "azureFunctionName"},
+	"http/cloudflare-worker":     {"url"},
+	"http/zapier":                {"url"},
+	"http/ifttt":                 {"webhookKey", "eventName"},
+	"http":                       {"url"},
+	"ingress/mongodb":            {"url", "database", "collection"},
+	"ingress-postgres-outbox":    {"url"},
+}
+
+// isIngress reports whether a rule type is for an ingress rule, which has no
+// source.
+func isIngress(ruleType string) bool {
+	return strings.HasPrefix(ruleType, "ingress")
+}
+
+func (a *app) validateRule(v validation, rule object) {
+	v.required(rule, "ruleType")
+	v.oneOf(rule, "status", "enabled", "disabled")
+	ruleType, _ := rule["ruleType"].(string)
+	required, ok := ruleTargets[ruleType]
+	if ruleType != "" && !ok {
+		v.add("ruleType", "unknown rule type %s", ruleType)
+	}
+	if _, isObject := rule["target"].(object); !isObject {
+		v.add("target", "is required")
+		return
+	}
+	for _, field := range required {
+		if field == "brokers" {
+			v.present(rule, "target."+field)
+		} else {
+			v.required(rule, "target."+field)
+		}
+	}
+	if !isIngress(ruleType) {
+		v.required(rule, "source.type")
+		v.oneOf(rule, "source.type", "channel.message", "channel.presence", "channel.lifecycle", "channel.occupancy")
+		v.oneOf(rule, "requestMode", "single", "batch")
+	}
+	if id, _ := lookup(rule, "target.queueId"); id != nil && id != "" {
+		if s, _ := id.(string); indexByID(a.queues, s) < 0 {
+			v.add("target.queueId", "queue %v not found", id)
+		}
+	}
+	if id, _ := lookup(rule, "target.signingKeyId"); id != nil && id != "" {
+		if s, _ := id.(string); indexByID(a.keys, s) < 0 {
+			v.add("target.signingKeyId", "key %v not found", id)
+		}
+	}
+}
+
+func (s *Server) listRules(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	return http.StatusOK, a.rules
+}
+
+func (s *Server) createRule(r *http.Request) (int, interface{}) {
+	a, err := s.app(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	v := validation{}
+	a.validateRule(v, body)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	created := now()
+	rule := object{
+		"id":       randomID(6),
+		"appId":    a.fields["id"],
+		"version":  "1.2",
+		"status":   "enabled",
+		"created":  created,
+		"modified": created,
+	}
+	if ruleType, _ := body["ruleType"].(string); !isIngress(ruleType) {
+		rule["requestMode"] = "single"
+	}
+	merge(rule, body, "id", "appId", "version", "created", "modified")
+	a.rules = append(a.rules, rule)
+	return http.StatusCreated, rule
+}
+
+// rule returns the index of the rule with the ID in the request path.
+func (s *Server) rule(r *http.Request) (*app, int, *Error) {
+	a, err := s.app(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	id := r.PathValue("ruleID")
+	i := indexByID(a.rules, id)
+	if i < 0 {
+		return nil, 0, errNotFound("Rule not found: " + id)
+	}
+	return a, i, nil
+}
+
+func (s *Server) getRule(r *http.Request) (int, interface{}) {
+	a, i, err := s.rule(r)
+	if err != nil {
+		return 0, err
+	}
+	return http.StatusOK, a.rules[i]
+}
+
+func (s *Server) updateRule(r *http.Request) (int, interface{}) {
+	a, i, err := s.rule(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := decode(r)
+	if err != nil {
+		return 0, err
+	}
+	rule := object{}
+	merge(rule, a.rules[i])
+	merge(rule, body, "id", "appId", "version", "created", "modified")
+	v := validation{}
+	a.validateRule(v, rule)
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+	rule["modified"] = now()
+	a.rules[i] = rule
+	return http.StatusOK, rule
+}
+
+func (s *Server) deleteRule(r *http.Request) (int, interface{}) {
+	a, i, err := s.rule(r)
+	if err != nil {
+		return 0, err
+	}
+	a.rules = slices.Delete(a.rules, i, i+1)
+	return http.StatusNoContent, nil
+}
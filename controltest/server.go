@@ -0,0 +1,277 @@
+// Package controltest provides an in-memory fake of the Ably Control API,
+// for testing code which uses the control package without an Ably account.
+//
+//	srv := controltest.NewServer()
+//	defer srv.Close()
+//
+//	client, _, err := control.NewClientWithURL(ctx, srv.Token, srv.URL)
+//
+// The fake keeps apps, keys, namespaces, queues, rules and ingress rules in
+// memory, assigns them IDs and timestamps, validates requests and responds
+// with the same error bodies as the Control API. It does not depend on the
+// control package, so it can also be used by the control package's own
+// tests.
+package controltest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithToken sets the access token the Server accepts. A random token is
+// used by default.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.Token = token
+	}
+}
+
+// WithAccountID sets the ID of the account the Server manages. A random ID
+// is used by default.
+func WithAccountID(accountID string) Option {
+	return func(s *Server) {
+		s.AccountID = accountID
+	}
+}
+
+// WithPageSize makes the Server split lists into pages of size items,
+// linked by Link headers. Lists are not split by default.
+func WithPageSize(size int) Option {
+	return func(s *Server) {
+		s.pageSize = size
+	}
+}
+
+// Server is a fake Control API.
+type Server struct {
+	*httptest.Server
+	// The access token which requests must be authenticated with.
+	Token string
+	// The ID of the account the Server manages.
+	AccountID string
+
+	pageSize int
+
+	mu   sync.Mutex
+	apps []*app
+}
+
+// object is a resource as it is encoded in JSON.
+type object = map[string]interface{}
+
+// app holds an app and the resources which belong to it.
+type app struct {
+	fields     object
+	keys       []object
+	namespaces []object
+	queues     []object
+	rules      []object
+}
+
+// NewServer starts a Server. The caller should call Close when finished, to
+// shut it down.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		Token:     randomID(32),
+		AccountID: randomID(6),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	mux := http.NewServeMux()
+	s.handle(mux, "GET /me", s.me)
+	s.handle(mux, "GET /accounts/{accountID}/apps", s.listApps)
+	s.handle(mux, "POST /accounts/{accountID}/apps", s.createApp)
+	s.handle(mux, "PATCH /apps/{appID}", s.updateApp)
+	s.handle(mux, "DELETE /apps/{appID}", s.deleteApp)
+	s.handle(mux, "GET /apps/{appID}/keys", s.listKeys)
+	s.handle(mux, "POST /apps/{appID}/keys", s.createKey)
+	s.handle(mux, "PATCH /apps/{appID}/keys/{keyID}", s.updateKey)
+	s.handle(mux, "POST /apps/{appID}/keys/{keyID}/revoke", s.revokeKey)
+	s.handle(mux, "GET /apps/{appID}/namespaces", s.listNamespaces)
+	s.handle(mux, "POST /apps/{appID}/namespaces", s.createNamespace)
+	s.handle(mux, "PATCH /apps/{appID}/namespaces/{namespaceID}", s.updateNamespace)
+	s.handle(mux, "DELETE /apps/{appID}/namespaces/{namespaceID}", s.deleteNamespace)
+	s.handle(mux, "GET /apps/{appID}/queues", s.listQueues)
+	s.handle(mux, "POST /apps/{appID}/queues", s.createQueue)
+	s.handle(mux, "DELETE /apps/{appID}/queues/{queueID}", s.deleteQueue)
+	s.handle(mux, "GET /apps/{appID}/rules", s.listRules)
+	s.handle(mux, "POST /apps/{appID}/rules", s.createRule)
+	s.handle(mux, "GET /apps/{appID}/rules/{ruleID}", s.getRule)
+	s.handle(mux, "PATCH /apps/{appID}/rules/{ruleID}", s.updateRule)
+	s.handle(mux, "DELETE /apps/{appID}/rules/{ruleID}", s.deleteRule)
+	s.handle(mux, "/", func(r *http.Request) (int, interface{}) {
+		return 0, errNotFound("Not found")
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// handler handles an authenticated request, returning the status code and
+// the value to encode in the response body. A *Error is returned as an
+// error response.
+type handler func(r *http.Request) (int, interface{})
+
+// handle registers h for pattern, wrapping it with authentication and
+// locking.
+func (s *Server) handle(mux *http.ServeMux, pattern string, h handler) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer "+s.Token || s.Token == "" {
+			writeError(w, &Error{StatusCode: http.StatusUnauthorized, Code: 40100, Message: "Access denied"})
+			return
+		}
+
+		status, v, body := s.call(w, r, h)
+		if err, ok := v.(*Error); ok {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(status)
+		if body != nil {
+			w.Write(append(body, '\n'))
+		}
+	})
+}
+
+// call calls h with the lock held, and returns the status code, the value
+// and the value encoded as JSON. The response is encoded while the lock is
+// held, since the value may refer to items which other requests change.
+func (s *Server) call(w http.ResponseWriter, r *http.Request, h handler) (int, interface{}, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, v := h(r)
+	if items, ok := v.([]object); ok {
+		v = s.page(w, r, items)
+	}
+	var body []byte
+	if _, ok := v.(*Error); !ok && v != nil {
+		body, _ = json.Marshal(v)
+	}
+	return status, v, body
+}
+
+// page returns the page of items requested by r, adding a Link header to
+// the next page if there is one.
+func (s *Server) page(w http.ResponseWriter, r *http.Request, items []object) []object {
+	if items == nil {
+		items = []object{}
+	}
+	if s.pageSize <= 0 {
+		return items
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	start := min((page-1)*s.pageSize, len(items))
+	end := min(start+s.pageSize, len(items))
+	if end < len(items) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next"`, r.URL.Path, page+1))
+	}
+	return items[start:end]
+}
+
+// Error is an error response, encoded as the Control API encodes errors.
+type Error struct {
+	Message    string              `json:"message"`
+	Code       int                 `json:"code"`
+	StatusCode int                 `json:"statusCode"`
+	HRef       string              `json:"href"`
+	Details    map[string][]string `json:"details,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, err *Error) {
+	err.HRef = href(err.Code)
+	w.WriteHeader(err.StatusCode)
+	json.NewEncoder(w).Encode(err)
+}
+
+// href returns the URL of the documentation for an error code.
+func href(code int) string {
+	return fmt.Sprintf("https://help.ably.io/error/%d", code)
+}
+
+func errNotFound(message string) *Error {
+	return &Error{StatusCode: http.StatusNotFound, Code: 40400, Message: message}
+}
+
+func errConflict(message string) *Error {
+	return &Error{StatusCode: http.StatusConflict, Code: 40900, Message: message}
+}
+
+// me handles GET /me.
+func (s *Server) me(r *http.Request) (int, interface{}) {
+	return http.StatusOK, object{
+		"token": object{
+			"id":           "controltest",
+			"name":         "controltest",
+			"capabilities": []string{"write:namespace", "read:namespace", "write:queue", "read:queue", "write:rule", "read:rule", "write:key", "read:key", "write:app", "read:app"},
+		},
+		"user":    object{"id": 1, "email": "controltest@example.com"},
+		"account": object{"id": s.AccountID, "name": "controltest"},
+	}
+}
+
+// app returns the app with the ID in the request path.
+func (s *Server) app(r *http.Request) (*app, *Error) {
+	id := r.PathValue("appID")
+	for _, a := range s.apps {
+		if a.fields["id"] == id {
+			return a, nil
+		}
+	}
+	return nil, errNotFound("App not found: " + id)
+}
+
+// decode decodes the JSON object in the body of r.
+func decode(r *http.Request) (object, *Error) {
+	var body object
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body == nil {
+		return nil, &Error{StatusCode: http.StatusBadRequest, Code: 40001, Message: "Invalid request body"}
+	}
+	return body, nil
+}
+
+// merge copies the fields of src into dst, except for those in omit.
+func merge(dst, src object, omit ...string) {
+	for k, v := range src {
+		if !slices.Contains(omit, k) {
+			dst[k] = v
+		}
+	}
+}
+
+// indexByID returns the index of the item with the given ID, or -1.
+func indexByID(items []object, id string) int {
+	return slices.IndexFunc(items, func(item object) bool {
+		return item["id"] == id
+	})
+}
+
+// now returns the current time as a Unix timestamp in milliseconds.
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+const idAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// randomID returns a random alphanumeric string of length n.
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		b[i] = idAlphabet[int(b[i])%len(idAlphabet)]
+	}
+	return string(b)
+}
@@ -0,0 +1,302 @@
+package controltest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	control "github.com/ably/ably-control-go"
+	"github.com/ably/ably-control-go/controltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClient(t *testing.T, opts ...controltest.Option) (*controltest.Server, control.Client) {
+	srv := controltest.NewServer(opts...)
+	t.Cleanup(srv.Close)
+	client, me, err := control.NewClientWithURL(context.Background(), srv.Token, srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, srv.AccountID, me.Account.ID)
+	return srv, client
+}
+
+func TestServerAuthentication(t *testing.T) {
+	ctx := context.Background()
+	srv := controltest.NewServer(controltest.WithToken("s3cr3t"), controltest.WithAccountID("acc"))
+	defer srv.Close()
+
+	_, _, err := control.NewClientWithURL(ctx, "wrong", srv.URL)
+	assert.ErrorIs(t, err, control.ErrUnauthorized)
+	var errorInfo control.ErrorInfo
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, "Access denied", errorInfo.Message)
+	assert.Equal(t, control.CodeUnauthorized, errorInfo.Code)
+	assert.Equal(t, "https://help.ably.io/error/40100", errorInfo.HRef)
+
+	_, me, err := control.NewClientWithURL(ctx, "s3cr3t", srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "acc", me.Account.ID)
+}
+
+func TestServerApps(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test", ApnsPrivateKey: "secret"})
+	assert.NoError(t, err)
+	assert.Len(t, app.ID, 6)
+	assert.Equal(t, client.AccountID(), app.AccountID)
+	assert.Equal(t, "enabled", app.Status)
+	assert.Empty(t, app.ApnsPrivateKey)
+
+	app, err = client.UpdateApp(ctx, app.ID, &control.AppUpdate{Status: control.String("disabled"), TLSOnly: control.Bool(true)})
+	assert.NoError(t, err)
+	assert.Equal(t, "test", app.Name)
+	assert.Equal(t, "disabled", app.Status)
+	assert.True(t, app.TLSOnly)
+
+	_, err = client.UpdateApp(ctx, app.ID, &control.AppUpdate{Status: control.String("paused")})
+	assert.ErrorIs(t, err, control.ErrValidation)
+
+	// app names are unique.
+	_, err = client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.ErrorIs(t, err, control.ErrConflict)
+	other, err := client.CreateApp(ctx, &control.NewApp{Name: "other"})
+	assert.NoError(t, err)
+	_, err = client.UpdateApp(ctx, other.ID, &control.AppUpdate{Name: control.String("test")})
+	assert.ErrorIs(t, err, control.ErrConflict)
+	assert.NoError(t, client.DeleteApp(ctx, other.ID))
+
+	apps, err := client.Apps(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []control.App{app}, apps)
+
+	assert.NoError(t, client.DeleteApp(ctx, app.ID))
+	err = client.DeleteApp(ctx, app.ID)
+	assert.ErrorIs(t, err, control.ErrNotFound)
+}
+
+func TestServerKeys(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+
+	key, err := client.CreateKey(ctx, app.ID, &control.NewKey{
+		Name:       "test",
+		Capability: map[string][]string{"*": {"publish", "subscribe"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, app.ID, key.AppID)
+	assert.Contains(t, key.Key, app.ID+"."+key.ID+":")
+	assert.NotZero(t, key.Created)
+
+	_, err = client.CreateKey(ctx, app.ID, &control.NewKey{
+		Name:       "test",
+		Capability: map[string][]string{"*": {"shout"}},
+	})
+	var errorInfo control.ErrorInfo
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, []control.FieldError{{
+		JSONPath:  "capability.*",
+		FieldPath: `NewKey.Capability["*"]`,
+		Messages:  []string{"invalid operation shout"},
+	}}, errorInfo.FieldErrors())
+
+	assert.NoError(t, client.RevokeKey(ctx, app.ID, key.ID))
+	keys, err := client.Keys(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, 1, keys[0].Status)
+}
+
+func TestServerNamespacesAndQueues(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+
+	_, err = client.CreateNamespace(ctx, app.ID, &control.Namespace{ID: "chat"})
+	assert.NoError(t, err)
+	_, err = client.CreateNamespace(ctx, app.ID, &control.Namespace{ID: "chat"})
+	assert.ErrorIs(t, err, control.ErrConflict)
+
+	queue, err := client.CreateQueue(ctx, app.ID, &control.NewQueue{Name: "events", Ttl: 60, MaxLength: 100, Region: control.EuWest1A})
+	assert.NoError(t, err)
+	assert.Equal(t, app.ID+":eu-west-1-a:events", queue.ID)
+	assert.Equal(t, app.ID+":events", queue.Amqp.QueueName)
+	_, err = client.CreateQueue(ctx, app.ID, &control.NewQueue{Name: "other", Ttl: 60, MaxLength: 100, Region: control.EuWest1A})
+	assert.NoError(t, err)
+
+	// the dead letter queue for the region is created along with the first
+	// queue in it.
+	queues, err := client.Queues(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, queues, 3)
+	assert.Equal(t, app.ID+":eu-west-1-a:deadletter", queues[1].ID)
+	assert.Equal(t, "deadletter", queues[1].Name)
+	assert.True(t, queues[1].DeadLetter)
+
+	_, err = client.CreateQueue(ctx, app.ID, &control.NewQueue{Name: "events", Ttl: 120, MaxLength: 100, Region: "mars"})
+	var errorInfo control.ErrorInfo
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, []string{"region", "ttl"}, jsonPaths(errorInfo.FieldErrors()))
+}
+
+func TestServerRules(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+
+	rule, err := client.CreateRule(ctx, app.ID, &control.NewRule{
+		Source: control.Source{ChannelFilter: "^chat", Type: control.ChannelMessage},
+		Target: &control.HttpTarget{Url: "https://example.com", Format: control.Json},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "enabled", rule.Status)
+	assert.Equal(t, control.Single, rule.RequestMode)
+
+	got, err := client.Rule(ctx, app.ID, rule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, rule, got)
+
+	_, err = client.CreateRule(ctx, app.ID, &control.NewRule{
+		Source: control.Source{Type: control.ChannelMessage},
+		Target: &control.AmqpTarget{QueueID: "missing"},
+	})
+	var errorInfo control.ErrorInfo
+	assert.True(t, errors.As(err, &errorInfo))
+	assert.Equal(t, []string{"target.queueId"}, jsonPaths(errorInfo.FieldErrors()))
+
+	ingress, err := client.CreateIngressRule(ctx, app.ID, &control.NewIngressRule{
+		Target: &control.IngressPostgresOutboxTarget{Url: "postgres://example.com/db"},
+	})
+	assert.NoError(t, err)
+	got2, err := client.IngressRule(ctx, app.ID, ingress.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ingress, got2)
+
+	assert.NoError(t, client.DeleteRule(ctx, app.ID, rule.ID))
+	_, err = client.Rule(ctx, app.ID, rule.ID)
+	assert.ErrorIs(t, err, control.ErrNotFound)
+}
+
+func TestServerPages(t *testing.T) {
+	ctx := context.Background()
+	srv := controltest.NewServer(controltest.WithPageSize(2))
+	defer srv.Close()
+
+	var requests int
+	counter := func(next control.Doer) control.Doer {
+		return control.DoerFunc(func(ctx context.Context, req *control.Request) (*http.Response, error) {
+			if req.Operation == "Namespaces" {
+				requests++
+			}
+			return next.Do(ctx, req)
+		})
+	}
+	client, _, err := control.NewClientWithURL(ctx, srv.Token, srv.URL, control.WithMiddleware(counter))
+	assert.NoError(t, err)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err := client.CreateNamespace(ctx, app.ID, &control.Namespace{ID: fmt.Sprint("ns", i)})
+		assert.NoError(t, err)
+	}
+
+	namespaces, err := client.Namespaces(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, namespaces, 5)
+	assert.Equal(t, "ns4", namespaces[4].ID)
+	assert.Equal(t, 3, requests)
+}
+
+func TestServerInvalidTypes(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		path, body string
+		fields     []string
+	}{
+		{"/apps/" + app.ID + "/queues", `{"name":5,"region":"us-east-1-a","ttl":60,"maxLength":100}`, []string{"name"}},
+		{"/apps/" + app.ID + "/namespaces", `{"id":5}`, []string{"id"}},
+		{"/apps/" + app.ID + "/rules", `{"ruleType":5,"target":{}}`, []string{"ruleType", "source.type"}},
+		{"/apps/" + app.ID + "/rules", `{"ruleType":"amqp","source":{"type":"channel.message"},"target":{"queueId":5}}`, []string{"target.queueId"}},
+		{"/accounts/" + srv.AccountID + "/apps", `{"name":5}`, []string{"name"}},
+	} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+test.path, strings.NewReader(test.body))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+srv.Token)
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		var body struct {
+			StatusCode int                 `json:"statusCode"`
+			Details    map[string][]string `json:"details"`
+		}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, test.body)
+		assert.ElementsMatch(t, test.fields, slices.Collect(maps.Keys(body.Details)), test.body)
+	}
+
+	// the server still answers requests.
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = client.Apps(ctx)
+	assert.NoError(t, err)
+}
+
+func TestServerConcurrentRequests(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	app, err := client.CreateApp(ctx, &control.NewApp{Name: "test"})
+	assert.NoError(t, err)
+	var keys []control.Key
+	for i := 0; i < 50; i++ {
+		key, err := client.CreateKey(ctx, app.ID, &control.NewKey{Name: fmt.Sprint("key", i), Capability: map[string][]string{"*": {"publish"}}})
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	// run with -race to check that responses are not encoded while other
+	// requests change the items in them.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, err := client.Keys(ctx, app.ID)
+				assert.NoError(t, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				key := keys[(i*20+j)%len(keys)]
+				_, err := client.UpdateKey(ctx, app.ID, key.ID, &control.KeyUpdate{Name: control.String(fmt.Sprint("key", i, j))})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func jsonPaths(fieldErrors []control.FieldError) []string {
+	var paths []string
+	for _, fieldError := range fieldErrors {
+		paths = append(paths, fieldError.JSONPath)
+	}
+	return paths
+}
@@ -0,0 +1,93 @@
+package controltest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// validation collects validation errors by the JSON path of the field they
+// apply to, such as "target.url".
+type validation map[string][]string
+
+func (v validation) add(path, format string, args ...interface{}) {
+	v[path] = append(v[path], fmt.Sprintf(format, args...))
+}
+
+// required checks that the field at path is a non-empty string.
+func (v validation) required(obj object, path string) {
+	value, ok := lookup(obj, path)
+	if !ok || value == nil || value == "" {
+		v.add(path, "is required")
+		return
+	}
+	if _, isString := value.(string); !isString {
+		v.add(path, "must be a string")
+	}
+}
+
+// present checks that the field at path is set, to a value of any type.
+func (v validation) present(obj object, path string) {
+	if value, ok := lookup(obj, path); !ok || value == nil || value == "" {
+		v.add(path, "is required")
+	}
+}
+
+// oneOf checks that the field at path, if it is set, is one of values.
+func (v validation) oneOf(obj object, path string, values ...string) {
+	value, ok := lookup(obj, path)
+	if !ok || value == nil {
+		return
+	}
+	if s, isString := value.(string); !isString || !slices.Contains(values, s) {
+		v.add(path, "must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+// between checks that the field at path is a number from min to max.
+func (v validation) between(obj object, path string, min, max float64) {
+	value, ok := lookup(obj, path)
+	if !ok || value == nil {
+		v.add(path, "is required")
+		return
+	}
+	if n, isNumber := value.(float64); !isNumber || n < min || n > max {
+		v.add(path, "must be a number from %g to %g", min, max)
+	}
+}
+
+// err returns the validation errors as an error response, or nil if there
+// are none.
+func (v validation) err() *Error {
+	if len(v) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(v))
+	for path := range v {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return &Error{
+		StatusCode: http.StatusBadRequest,
+		Code:       40000,
+		Message:    "Validation failed: " + strings.Join(paths, ", "),
+		Details:    v,
+	}
+}
+
+// lookup returns the value of the field at a path such as "target.url".
+func lookup(obj object, path string) (interface{}, bool) {
+	var value interface{} = obj
+	for _, name := range strings.Split(path, ".") {
+		m, ok := value.(object)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,164 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ably/ably-control-go/internal/redact"
+)
+
+// PlannedOperation is a call which a Client in dry-run mode did not send.
+type PlannedOperation struct {
+	// The name of the Client method which made the call, such as
+	// "CreateApp".
+	Operation string
+	// The HTTP method, such as "POST".
+	Method string
+	// The API path, such as "/apps/{appID}/keys".
+	Path string
+	// The JSON request body with secrets redacted, or nil if the request
+	// has no body.
+	Body json.RawMessage
+}
+
+// String returns the operation as a line such as
+// "CreateKey POST /apps/abc/keys {...}".
+func (op PlannedOperation) String() string {
+	s := op.Operation + " " + op.Method + " " + op.Path
+	if op.Body != nil {
+		s += " " + string(op.Body)
+	}
+	return s
+}
+
+// DryRun records the calls which change resources, made by a Client created
+// with WithDryRun, instead of sending them.
+type DryRun struct {
+	mu         sync.Mutex
+	operations []PlannedOperation
+}
+
+// NewDryRun creates a DryRun with no planned operations.
+func NewDryRun() *DryRun {
+	return &DryRun{}
+}
+
+// Operations returns the planned operations, in the order they were made.
+func (d *DryRun) Operations() []PlannedOperation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]PlannedOperation(nil), d.operations...)
+}
+
+// Reset forgets the planned operations.
+func (d *DryRun) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.operations = nil
+}
+
+// WithDryRun puts the Client in dry-run mode. Calls which change resources,
+// which are POST, PATCH and DELETE requests, are recorded in d rather than
+// sent. GET requests are still sent, so the Client can read the current
+// state of the account.
+//
+// A call which is not sent returns a result synthesized from its request:
+// the request body, with the resource ID taken from the path, or a
+// placeholder ID such as "dry-run-1" for a resource which would be created.
+// Fields which the Control API would fill in, such as timestamps, are left
+// empty.
+//
+// Dry-run mode is applied inside any middleware given to WithMiddleware, so
+// middleware sees the calls which are not sent.
+func WithDryRun(d *DryRun) Option {
+	return func(o *options) {
+		o.dryRun = d
+	}
+}
+
+// middleware returns the Middleware which records and answers mutating
+// calls.
+func (d *DryRun) middleware(next Doer) Doer {
+	return DoerFunc(func(ctx context.Context, req *Request) (*http.Response, error) {
+		if req.Method == http.MethodGet {
+			return next.Do(ctx, req)
+		}
+		var body []byte
+		if req.Body != nil {
+			var err error
+			body, err = json.Marshal(req.Body)
+			if err != nil {
+				return nil, err
+			}
+		}
+		op := PlannedOperation{
+			Operation: req.Operation,
+			Method:    req.Method,
+			Path:      req.Path,
+		}
+		if body != nil {
+			op.Body = redact.JSON(body)
+		}
+		d.mu.Lock()
+		d.operations = append(d.operations, op)
+		n := len(d.operations)
+		d.mu.Unlock()
+
+		return synthesize(req, body, n)
+	})
+}
+
+// synthesize returns the response to a call which was not sent, the nth
+// planned by a DryRun.
+func synthesize(req *Request, body []byte, n int) (*http.Response, error) {
+	res := &http.Response{
+		Status:     "204 No Content",
+		StatusCode: http.StatusNoContent,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}
+	if req.Method == http.MethodDelete || strings.HasSuffix(req.Path, "/revoke") {
+		return res, nil
+	}
+
+	out := map[string]interface{}{}
+	if body != nil {
+		if err := json.Unmarshal(body, &out); err != nil || out == nil {
+			out = map[string]interface{}{}
+		}
+	}
+	// the path is either /accounts/{accountID}/apps, /apps/{appID}, or
+	// /apps/{appID}/{resources}[/{id}].
+	segments := strings.Split(strings.Trim(req.Path, "/"), "/")
+	switch {
+	case segments[0] == "accounts" && len(segments) >= 2:
+		out["accountId"] = segments[1]
+	case segments[0] == "apps" && len(segments) == 2:
+		out["id"] = segments[1]
+	case segments[0] == "apps" && len(segments) >= 3:
+		out["appId"] = segments[1]
+	}
+	if req.Method == http.MethodPatch && len(segments) >= 4 {
+		out["id"] = segments[3]
+	}
+	if id, _ := out["id"].(string); id == "" {
+		out["id"] = fmt.Sprintf("dry-run-%d", n)
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	res.Status = "200 OK"
+	res.StatusCode = http.StatusOK
+	res.Header.Set("Content-Type", "application/json")
+	res.Body = io.NopCloser(bytes.NewReader(data))
+	res.ContentLength = int64(len(data))
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ably/ably-control-go/controltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	srv := controltest.NewServer()
+	defer srv.Close()
+
+	// create an app to read from, without dry-run mode.
+	live, err := NewClientWithAccountID(srv.Token, srv.AccountID, WithURL(srv.URL))
+	assert.NoError(t, err)
+	app, err := live.CreateApp(ctx, &NewApp{Name: "live"})
+	assert.NoError(t, err)
+
+	var seen []string
+	observer := func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*http.Response, error) {
+			seen = append(seen, req.Operation)
+			return next.Do(ctx, req)
+		})
+	}
+	dryRun := NewDryRun()
+	client, err := NewClientWithAccountID(srv.Token, srv.AccountID,
+		WithURL(srv.URL),
+		WithDryRun(dryRun),
+		WithMiddleware(observer),
+	)
+	assert.NoError(t, err)
+
+	created, err := client.CreateApp(ctx, &NewApp{Name: "planned", FcmKey: "s3cr3t"})
+	assert.NoError(t, err)
+	assert.Equal(t, "dry-run-1", created.ID)
+	assert.Equal(t, "planned", created.Name)
+	assert.Equal(t, srv.AccountID, created.AccountID)
+
+	key, err := client.UpdateKey(ctx, app.ID, "key", &KeyUpdate{Name: String("renamed")})
+	assert.NoError(t, err)
+	assert.Equal(t, "key", key.ID)
+	assert.Equal(t, app.ID, key.AppID)
+	assert.Equal(t, "renamed", key.Name)
+
+	rule, err := client.CreateRule(ctx, app.ID, &NewRule{
+		Source: Source{Type: ChannelMessage},
+		Target: &HttpTarget{Url: "https://example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "dry-run-3", rule.ID)
+	assert.Equal(t, &HttpTarget{Url: "https://example.com"}, rule.Target)
+
+	assert.NoError(t, client.RevokeKey(ctx, app.ID, "key"))
+	assert.NoError(t, client.DeleteApp(ctx, app.ID))
+
+	// GETs are still sent.
+	apps, err := client.Apps(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []App{app}, apps)
+
+	ops := dryRun.Operations()
+	assert.Len(t, ops, 5)
+	assert.Equal(t, "CreateApp", ops[0].Operation)
+	assert.Equal(t, "POST", ops[0].Method)
+	assert.Equal(t, "/accounts/"+srv.AccountID+"/apps", ops[0].Path)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(ops[0].Body, &body))
+	assert.Equal(t, "planned", body["name"])
+	assert.Equal(t, "[REDACTED]", body["fcmKey"])
+	assert.Equal(t, "RevokeKey POST /apps/"+app.ID+"/keys/key/revoke", ops[3].String())
+	assert.Nil(t, ops[4].Body)
+
+	assert.Equal(t, []string{"CreateApp", "UpdateKey", "CreateRule", "RevokeKey", "DeleteApp", "Apps"}, seen)
+
+	dryRun.Reset()
+	assert.Empty(t, dryRun.Operations())
+}
@@ -1,7 +1,32 @@
 package control
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors which an ErrorInfo matches with errors.Is, based on its
+// status code.
+var (
+	// ErrValidation is matched by errors for requests which failed
+	// validation, with a 400 or 422 status code.
+	ErrValidation = errors.New("control: validation failed")
+	// ErrUnauthorized is matched by errors with a 401 status code.
+	ErrUnauthorized = errors.New("control: unauthorized")
+	// ErrForbidden is matched by errors with a 403 status code.
+	ErrForbidden = errors.New("control: forbidden")
+	// ErrNotFound is matched by errors with a 404 status code.
+	ErrNotFound = errors.New("control: not found")
+	// ErrConflict is matched by errors with a 409 status code.
+	ErrConflict = errors.New("control: conflict")
+	// ErrRateLimited is matched by errors with a 429 status code.
+	ErrRateLimited = errors.New("control: rate limited")
+	// ErrAmbiguous is matched by an *AmbiguousNameError, returned by
+	// lookups such as FindAppByName when more than one resource has the
+	// name.
+	ErrAmbiguous = errors.New("control: ambiguous name")
 )
 
 // ErrorInfo represents an error type that the REST API may return.
@@ -18,6 +43,15 @@ type ErrorInfo struct {
 	Details map[string][]string `json:"details"`
 	// The API path that resulted in this error.
 	APIPath string
+	// The number of attempts made for the request, including retries.
+	Attempts int
+
+	// request is the value sent in the body of the request, which is used
+	// to map Details onto Go field paths.
+	request interface{}
+	// notJSON is set if Message is the body of a response which was not
+	// JSON, so it can't be known what it contains.
+	notJSON bool
 }
 
 // ErrorInfo implements the Error interface.
@@ -41,3 +75,83 @@ func (e ErrorInfo) Error() string {
 	}
 	return err
 }
+
+// Is reports whether the error matches target, so that errors.Is can be
+// used to check an ErrorInfo against the sentinel errors such as
+// ErrNotFound.
+func (e ErrorInfo) Is(target error) bool {
+	statusCode := e.StatusCode
+	if statusCode == 0 {
+		// Ably error codes start with the HTTP status code.
+		statusCode = e.Code / 100
+	}
+	switch target {
+	case ErrValidation:
+		return statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return statusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return statusCode == http.StatusForbidden
+	case ErrNotFound:
+		return statusCode == http.StatusNotFound
+	case ErrConflict:
+		return statusCode == http.StatusConflict
+	case ErrRateLimited:
+		return statusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// TransportError is returned when a request could not be sent or no
+// response was received, for example because of a DNS, TLS or timeout
+// error.
+type TransportError struct {
+	// The HTTP method of the request.
+	Method string
+	// The API path of the request.
+	APIPath string
+	// The underlying error.
+	Err error
+}
+
+// TransportError implements the Error interface.
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Method, e.APIPath, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// AmbiguousNameError is returned by lookups such as FindAppByName when
+// more than one resource has the name.
+type AmbiguousNameError struct {
+	// The type of resource, such as "app".
+	Resource string
+	// The name which was looked up.
+	Name string
+	// The IDs of the resources with the name.
+	IDs []string
+}
+
+// AmbiguousNameError implements the Error interface.
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("control: %d %ss are named %q: %s", len(e.IDs), e.Resource, e.Name, strings.Join(e.IDs, ", "))
+}
+
+// Unwrap returns ErrAmbiguous.
+func (e *AmbiguousNameError) Unwrap() error {
+	return ErrAmbiguous
+}
+
+// notFound returns the error for a resource which is not in a list fetched
+// from path.
+func notFound(resource, id, path string) ErrorInfo {
+	return ErrorInfo{
+		Message:    fmt.Sprintf("%s not found: %s", resource, id),
+		Code:       CodeNotFound,
+		StatusCode: http.StatusNotFound,
+		APIPath:    path,
+	}
+}
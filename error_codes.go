@@ -0,0 +1,161 @@
+package control
+
+import "net/http"
+
+// Ably error codes which the Control API may return in ErrorInfo.Code.
+//
+// See https://help.ably.io for more information about each code.
+const (
+	// CodeBadRequest is returned for malformed or invalid requests.
+	CodeBadRequest = 40000
+	// CodeInvalidRequestBody is returned when the request body is invalid.
+	CodeInvalidRequestBody = 40001
+	// CodeInvalidParameterName is returned for unknown parameters.
+	CodeInvalidParameterName = 40002
+	// CodeInvalidParameterValue is returned for invalid parameter values.
+	CodeInvalidParameterValue = 40003
+	// CodeInvalidHeader is returned when a request header is invalid.
+	CodeInvalidHeader = 40004
+	// CodeUnauthorized is returned when the request is not authenticated.
+	CodeUnauthorized = 40100
+	// CodeInvalidCredentials is returned when the access token is invalid.
+	CodeInvalidCredentials = 40101
+	// CodeTokenError is returned for errors with the access token.
+	CodeTokenError = 40140
+	// CodeTokenRevoked is returned when the access token has been revoked.
+	CodeTokenRevoked = 40141
+	// CodeTokenExpired is returned when the access token has expired.
+	CodeTokenExpired = 40142
+	// CodeTokenUnrecognized is returned when the access token is unknown.
+	CodeTokenUnrecognized = 40143
+	// CodeActionNotPermitted is returned when the access token lacks the
+	// capability for the request.
+	CodeActionNotPermitted = 40160
+	// CodeForbidden is returned when the request is not permitted.
+	CodeForbidden = 40300
+	// CodeNotFound is returned when a resource does not exist.
+	CodeNotFound = 40400
+	// CodeMethodNotAllowed is returned when the HTTP method is not allowed.
+	CodeMethodNotAllowed = 40500
+	// CodeRateLimitExceeded is returned when a rate limit was exceeded and
+	// the request was rejected.
+	CodeRateLimitExceeded = 42910
+	// CodeRateLimitExceededFatal is returned when a rate limit was exceeded
+	// and further requests will be rejected.
+	CodeRateLimitExceededFatal = 42920
+	// CodeInternalError is returned for unexpected server errors.
+	CodeInternalError = 50000
+	// CodeTimeout is returned when the server timed out processing the
+	// request.
+	CodeTimeout = 50003
+	// CodeServiceOverloaded is returned when the request failed because the
+	// service is overloaded.
+	CodeServiceOverloaded = 50010
+	// CodeEdgeProxyError is returned when Ably's edge proxy failed to
+	// handle the request.
+	CodeEdgeProxyError = 50210
+	// CodeEdgeProxyBadGateway is returned when Ably's edge proxy received an
+	// invalid response.
+	CodeEdgeProxyBadGateway = 50310
+	// CodeTrafficRedirected is returned when traffic is temporarily being
+	// redirected away from a cluster.
+	CodeTrafficRedirected = 50320
+	// CodeEdgeProxyTimeout is returned when Ably's edge proxy timed out
+	// waiting for a response.
+	CodeEdgeProxyTimeout = 50410
+)
+
+// ErrorCodeInfo describes an Ably error code.
+type ErrorCodeInfo struct {
+	// The Ably error code.
+	Code int
+	// A human readable description of the error.
+	Description string
+	// True if repeating the same request later may succeed.
+	Retryable bool
+	// True if the request can not succeed without being changed. Errors
+	// which are neither retryable nor permanent, such as an expired access
+	// token, need some other action before the request can be repeated.
+	Permanent bool
+}
+
+var errorCodes = map[int]ErrorCodeInfo{
+	CodeBadRequest:             {Description: "bad request", Permanent: true},
+	CodeInvalidRequestBody:     {Description: "invalid request body", Permanent: true},
+	CodeInvalidParameterName:   {Description: "invalid parameter name", Permanent: true},
+	CodeInvalidParameterValue:  {Description: "invalid parameter value", Permanent: true},
+	CodeInvalidHeader:          {Description: "invalid header", Permanent: true},
+	CodeUnauthorized:           {Description: "unauthorized"},
+	CodeInvalidCredentials:     {Description: "invalid credentials"},
+	CodeTokenError:             {Description: "access token error"},
+	CodeTokenRevoked:           {Description: "access token revoked"},
+	CodeTokenExpired:           {Description: "access token expired"},
+	CodeTokenUnrecognized:      {Description: "access token unrecognized"},
+	CodeActionNotPermitted:     {Description: "action not permitted by the access token", Permanent: true},
+	CodeForbidden:              {Description: "forbidden", Permanent: true},
+	CodeNotFound:               {Description: "not found", Permanent: true},
+	CodeMethodNotAllowed:       {Description: "method not allowed", Permanent: true},
+	CodeRateLimitExceeded:      {Description: "rate limit exceeded; request rejected", Retryable: true},
+	CodeRateLimitExceededFatal: {Description: "rate limit exceeded; fatal"},
+	CodeInternalError:          {Description: "internal error", Retryable: true},
+	CodeTimeout:                {Description: "timeout error", Retryable: true},
+	CodeServiceOverloaded:      {Description: "request failed due to overloaded instance", Retryable: true},
+	CodeEdgeProxyError:         {Description: "edge proxy service error", Retryable: true},
+	CodeEdgeProxyBadGateway:    {Description: "edge proxy service received an invalid response", Retryable: true},
+	CodeTrafficRedirected:      {Description: "traffic is temporarily being redirected", Retryable: true},
+	CodeEdgeProxyTimeout:       {Description: "edge proxy service timed out", Retryable: true},
+}
+
+// LookupErrorCode returns the catalogue entry for an Ably error code.
+//
+// Codes which are not in the catalogue are described by the HTTP status
+// code they start with, in which case ok is false.
+func LookupErrorCode(code int) (info ErrorCodeInfo, ok bool) {
+	info, ok = errorCodes[code]
+	if !ok {
+		info = statusCodeInfo(code / 100)
+	}
+	info.Code = code
+	return info, ok
+}
+
+// statusCodeInfo describes the errors for an HTTP status code.
+func statusCodeInfo(statusCode int) ErrorCodeInfo {
+	info := ErrorCodeInfo{Description: http.StatusText(statusCode)}
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		info.Retryable = true
+	case statusCode == http.StatusUnauthorized:
+	case statusCode >= 400:
+		info.Permanent = true
+	}
+	return info
+}
+
+// codeInfo returns the catalogue entry for the error, falling back to its
+// status code if its error code is unknown.
+func (e ErrorInfo) codeInfo() ErrorCodeInfo {
+	if e.Code != 0 {
+		if info, ok := LookupErrorCode(e.Code); ok || e.StatusCode == 0 {
+			return info
+		}
+	}
+	return statusCodeInfo(e.StatusCode)
+}
+
+// IsRetryable reports whether repeating the request which failed may
+// succeed, based on the error code catalogue.
+func (e ErrorInfo) IsRetryable() bool {
+	return e.codeInfo().Retryable
+}
+
+// IsPermanent reports whether the request which failed can not succeed
+// without being changed, based on the error code catalogue.
+func (e ErrorInfo) IsPermanent() bool {
+	return e.codeInfo().Permanent
+}
+
+// Description returns a human readable description of the error code.
+func (e ErrorInfo) Description() string {
+	return e.codeInfo().Description
+}
@@ -0,0 +1,48 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupErrorCode(t *testing.T) {
+	info, ok := LookupErrorCode(CodeRateLimitExceeded)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCodeInfo{
+		Code:        42910,
+		Description: "rate limit exceeded; request rejected",
+		Retryable:   true,
+	}, info)
+
+	// unknown codes are described by their status code.
+	info, ok = LookupErrorCode(40499)
+	assert.False(t, ok)
+	assert.Equal(t, ErrorCodeInfo{
+		Code:        40499,
+		Description: "Not Found",
+		Permanent:   true,
+	}, info)
+}
+
+func TestErrorInfoCodes(t *testing.T) {
+	for _, test := range []struct {
+		errorInfo   ErrorInfo
+		retryable   bool
+		permanent   bool
+		description string
+	}{
+		{ErrorInfo{Code: 40000, StatusCode: 400}, false, true, "bad request"},
+		{ErrorInfo{Code: 40142, StatusCode: 401}, false, false, "access token expired"},
+		{ErrorInfo{Code: 42910, StatusCode: 429}, true, false, "rate limit exceeded; request rejected"},
+		{ErrorInfo{Code: 42920, StatusCode: 429}, false, false, "rate limit exceeded; fatal"},
+		{ErrorInfo{Code: 50000, StatusCode: 500}, true, false, "internal error"},
+		{ErrorInfo{StatusCode: 502}, true, false, "Bad Gateway"},
+		{ErrorInfo{Code: 40977, StatusCode: 409}, false, true, "Conflict"},
+		{ErrorInfo{Code: 50399}, true, false, "Service Unavailable"},
+	} {
+		assert.Equal(t, test.retryable, test.errorInfo.IsRetryable(), test.errorInfo)
+		assert.Equal(t, test.permanent, test.errorInfo.IsPermanent(), test.errorInfo)
+		assert.Equal(t, test.description, test.errorInfo.Description(), test.errorInfo)
+	}
+}
@@ -1,13 +1,19 @@
 package control
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/ably/ably-control-go/controltest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestError(t *testing.T) {
-	_, _, err := NewClientWithURL("", url)
+	ctx := context.Background()
+	_, _, err := NewClientWithURL(ctx, "", url)
 	errorInfo := err.(ErrorInfo)
 
 	expected := ErrorInfo{
@@ -16,7 +22,57 @@ func TestError(t *testing.T) {
 		StatusCode: 401,
 		HRef:       "https://help.ably.io/error/40100",
 		APIPath:    "/me",
+		Attempts:   1,
 	}
 
 	assert.Equal(t, expected, errorInfo)
 }
+
+func TestErrorIs(t *testing.T) {
+	err := error(ErrorInfo{Message: "Not found", Code: 40400, StatusCode: 404})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrUnauthorized)
+
+	err = fmt.Errorf("wrapped: %w", ErrorInfo{Code: 40000, StatusCode: 400})
+	assert.ErrorIs(t, err, ErrValidation)
+
+	// the status code is taken from the error code if not set.
+	assert.ErrorIs(t, ErrorInfo{Code: 42910}, ErrRateLimited)
+	assert.ErrorIs(t, ErrorInfo{Code: 40300}, ErrForbidden)
+	assert.ErrorIs(t, ErrorInfo{StatusCode: 409}, ErrConflict)
+}
+
+func TestTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	ctx := context.Background()
+	client, err := NewClientWithAccountID("s3cr3t", "acc", WithURL(srv.URL))
+	assert.NoError(t, err)
+
+	err = client.DeleteApp(ctx, "app")
+	var transportErr *TransportError
+	assert.ErrorAs(t, err, &transportErr)
+	assert.Equal(t, "DELETE", transportErr.Method)
+	assert.Equal(t, "/apps/app", transportErr.APIPath)
+}
+
+// TestErrorNonJSON tests that an error response whose body isn't JSON is
+// returned with the body as its message.
+func TestErrorNonJSON(t *testing.T) {
+	ctx := context.Background()
+	srv := controltest.NewServer()
+	defer srv.Close()
+	faults := controltest.NewTransport(nil)
+	faults.Inject(controltest.FaultRule{Fault: controltest.HTMLError(http.StatusServiceUnavailable)})
+	client, err := NewClientWithAccountID(srv.Token, srv.AccountID, WithURL(srv.URL), WithTransport(faults))
+	assert.NoError(t, err)
+
+	_, err = client.Apps(ctx)
+	var errorInfo ErrorInfo
+	assert.ErrorAs(t, err, &errorInfo)
+	assert.Equal(t, http.StatusServiceUnavailable, errorInfo.StatusCode)
+	assert.Equal(t, 0, errorInfo.Code)
+	assert.Equal(t, "/accounts/"+srv.AccountID+"/apps", errorInfo.APIPath)
+	assert.Contains(t, errorInfo.Message, "<title>503 Service Unavailable</title>")
+}
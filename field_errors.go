@@ -0,0 +1,181 @@
+package control
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError is a validation error for a single field of a request.
+type FieldError struct {
+	// The path of the field in the JSON request body, as reported in
+	// ErrorInfo.Details. For example "target.routingKey".
+	JSONPath string
+	// The path of the field in the Go value passed to the method which
+	// failed. For example "NewRule.Target.(*KafkaTarget).RoutingKey".
+	// Segments which do not match a Go field are kept as they appear in
+	// JSONPath.
+	FieldPath string
+	// The validation messages for the field.
+	Messages []string
+}
+
+// FieldErrors returns the validation errors in Details with their Go field
+// paths, sorted by JSON path.
+func (e ErrorInfo) FieldErrors() []FieldError {
+	if len(e.Details) == 0 {
+		return nil
+	}
+	fieldErrors := make([]FieldError, 0, len(e.Details))
+	for jsonPath, messages := range e.Details {
+		fieldErrors = append(fieldErrors, FieldError{
+			JSONPath:  jsonPath,
+			FieldPath: e.FieldPath(jsonPath),
+			Messages:  messages,
+		})
+	}
+	sort.Slice(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].JSONPath < fieldErrors[j].JSONPath
+	})
+	return fieldErrors
+}
+
+// FieldPath maps a JSON path from Details, such as "target.routingKey", onto
+// the path of the field in the Go value passed to the method which failed,
+// such as "NewRule.Target.(*KafkaTarget).RoutingKey".
+//
+// If the error was not caused by a request with a body, jsonPath is
+// returned unchanged.
+func (e ErrorInfo) FieldPath(jsonPath string) string {
+	v := indirect(reflect.ValueOf(e.request))
+	if !v.IsValid() {
+		return jsonPath
+	}
+
+	path := v.Type().Name()
+	segments := splitJSONPath(jsonPath)
+	for i, segment := range segments {
+		next, fieldPath, ok := fieldByJSONName(v, segment)
+		if !ok {
+			return path + "." + strings.Join(segments[i:], ".")
+		}
+		path += fieldPath
+		v = next
+	}
+	return path
+}
+
+// splitJSONPath splits a path such as "headers[0].name" into its segments.
+func splitJSONPath(jsonPath string) []string {
+	var segments []string
+	for _, segment := range strings.Split(jsonPath, ".") {
+		for {
+			i := strings.IndexByte(segment, '[')
+			if i < 0 || !strings.HasSuffix(segment, "]") {
+				break
+			}
+			if i > 0 {
+				segments = append(segments, segment[:i])
+			}
+			segment = strings.TrimSuffix(segment[i+1:], "]")
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// fieldByJSONName returns the element of v which is encoded with the given
+// name in JSON, along with the Go path to it from v.
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, string, bool) {
+	switch v.Kind() {
+	case reflect.Struct:
+		return structFieldByJSONName(v, name)
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(name)
+		if err != nil || i < 0 || i >= v.Len() {
+			return reflect.Value{}, "", false
+		}
+		next, assertion := unwrapInterface(v.Index(i))
+		return next, fmt.Sprintf("[%d]%s", i, assertion), true
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, "", false
+		}
+		next, assertion := unwrapInterface(v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())))
+		return next, fmt.Sprintf("[%q]%s", name, assertion), true
+	}
+	return reflect.Value{}, "", false
+}
+
+func structFieldByJSONName(v reflect.Value, name string) (reflect.Value, string, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			// fields of embedded structs are promoted.
+			if embedded := indirect(v.Field(i)); embedded.Kind() == reflect.Struct {
+				if next, path, ok := structFieldByJSONName(embedded, name); ok {
+					return next, path, true
+				}
+			}
+			continue
+		}
+		if tag == name || (tag == "" && strings.EqualFold(field.Name, name)) {
+			next, assertion := unwrapInterface(v.Field(i))
+			return next, "." + field.Name + assertion, true
+		}
+	}
+
+	// some types, such as AwsAuthentication, flatten the fields of an
+	// interface value into their own JSON object.
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("json") != "" || field.Type.Kind() != reflect.Interface {
+			continue
+		}
+		inner, assertion := unwrapInterface(v.Field(i))
+		if inner.Kind() != reflect.Struct {
+			continue
+		}
+		if next, path, ok := structFieldByJSONName(inner, name); ok {
+			return next, "." + field.Name + assertion + path, true
+		}
+	}
+	return reflect.Value{}, "", false
+}
+
+// unwrapInterface dereferences v, returning a type assertion to the
+// dynamic type if v is an interface.
+func unwrapInterface(v reflect.Value) (reflect.Value, string) {
+	var assertion string
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		dynamic := v.Elem().Type()
+		if dynamic.Kind() == reflect.Pointer {
+			assertion = ".(*" + dynamic.Elem().Name() + ")"
+		} else {
+			assertion = ".(" + dynamic.Name() + ")"
+		}
+	}
+	return indirect(v), assertion
+}
+
+// indirect dereferences pointers and interfaces until it reaches a
+// concrete value.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,74 @@
+package control
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldErrors(t *testing.T) {
+	ctx := context.Background()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{
+			"message": "Validation failed",
+			"code": 40000,
+			"statusCode": 400,
+			"details": {
+				"target.routingKey": ["must be in the format topic:key"],
+				"target.auth.sasl.password": ["is required"],
+				"source.type": ["is invalid"],
+				"ruleType": ["is invalid"]
+			}
+		}`))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client, err := NewClientWithAccountID("s3cr3t", "acc", WithURL(srv.URL))
+	assert.NoError(t, err)
+
+	rule := NewRule{
+		Target: &KafkaTarget{RoutingKey: "bad"},
+	}
+	_, err = client.CreateRule(ctx, "app", &rule)
+	assert.ErrorIs(t, err, ErrValidation)
+
+	errorInfo := err.(ErrorInfo)
+	assert.Equal(t, []FieldError{
+		{JSONPath: "ruleType", FieldPath: "NewRule.ruleType", Messages: []string{"is invalid"}},
+		{JSONPath: "source.type", FieldPath: "NewRule.Source.Type", Messages: []string{"is invalid"}},
+		{JSONPath: "target.auth.sasl.password", FieldPath: "NewRule.Target.(*KafkaTarget).Authentication.Sasl.Password", Messages: []string{"is required"}},
+		{JSONPath: "target.routingKey", FieldPath: "NewRule.Target.(*KafkaTarget).RoutingKey", Messages: []string{"must be in the format topic:key"}},
+	}, errorInfo.FieldErrors())
+}
+
+func TestFieldPath(t *testing.T) {
+	errorInfo := ErrorInfo{request: &NewRule{
+		Target: &AwsSqsTarget{
+			Authentication: AwsAuthentication{
+				Authentication: &AuthenticationModeCredentials{},
+			},
+		},
+	}}
+	assert.Equal(t,
+		"NewRule.Target.(*AwsSqsTarget).Authentication.Authentication.(*AuthenticationModeCredentials).SecretAccessKey",
+		errorInfo.FieldPath("target.authentication.secretAccessKey"))
+
+	errorInfo = ErrorInfo{request: &NewRule{
+		Target: &HttpTarget{Headers: []Header{{}, {}}},
+	}}
+	assert.Equal(t, "NewRule.Target.(*HttpTarget).Headers[1].Name", errorInfo.FieldPath("target.headers[1].name"))
+	assert.Equal(t, "NewRule.Target.(*HttpTarget).Headers[1].Name", errorInfo.FieldPath("target.headers.1.name"))
+	assert.Equal(t, "NewRule.Target.(*HttpTarget).Headers.5.name", errorInfo.FieldPath("target.headers.5.name"))
+
+	errorInfo = ErrorInfo{request: &NewKey{Capability: map[string][]string{"a": {"publish"}}}}
+	assert.Equal(t, `NewKey.Capability["a"]`, errorInfo.FieldPath("capability.a"))
+
+	// errors for requests without a body are returned unchanged.
+	assert.Equal(t, "name", ErrorInfo{}.FieldPath("name"))
+}
@@ -0,0 +1,132 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader is the HTTP header which carries the idempotency key
+// of a create call, such as CreateApp.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyContextKey struct{}
+
+// ContextWithIdempotencyKey returns a copy of ctx which makes a create call,
+// such as CreateApp, use key as its idempotency key. Passing the same key
+// when repeating a create call whose outcome is unknown allows the Control
+// API to recognise it as a duplicate.
+//
+// Create calls made without a key use a randomly generated one, which is
+// shared by all the attempts made by the call.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// idempotencyKey returns the idempotency key for a create call made with
+// ctx.
+func idempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyContextKey{}).(string); ok && key != "" {
+		return key
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// deriveIdempotencyKey returns ctx for one of the create calls made by an
+// operation such as CloneApp. If ctx carries an idempotency key, the
+// returned context carries a key derived from it and name, so that each
+// call has its own key, and repeating the operation with the same key
+// repeats the same keys.
+func deriveIdempotencyKey(ctx context.Context, name string) context.Context {
+	key, ok := ctx.Value(idempotencyKeyContextKey{}).(string)
+	if !ok || key == "" {
+		return ctx
+	}
+	return ContextWithIdempotencyKey(ctx, key+"/"+name)
+}
+
+// createdKeySkew is how much earlier than the start of a CreateKey call a key
+// may appear to have been created and still be recognised as created by it,
+// allowing for clock differences with the Control API.
+const createdKeySkew = time.Minute
+
+// finder looks for a resource created by an earlier attempt at a create
+// call, whose response was lost.
+type finder func(ctx context.Context) (interface{}, bool, error)
+
+// findFirst returns a finder which returns the first item matching match.
+func findFirst[T any](items func(ctx context.Context) iter.Seq2[T, error], match func(T) bool) finder {
+	return func(ctx context.Context) (interface{}, bool, error) {
+		for item, err := range items(ctx) {
+			if err != nil {
+				return nil, false, err
+			}
+			if match(item) {
+				return item, true, nil
+			}
+		}
+		return nil, false, nil
+	}
+}
+
+// findNew returns a finder like findFirst, which ignores the items which
+// already match when findNew is called, since they can't have been created
+// by the call. It returns nil if the items can't be listed, or if the
+// Client does not retry requests, in which case there is nothing to find.
+func findNew[T any](ctx context.Context, c *Client, items func(ctx context.Context) iter.Seq2[T, error], match func(T) bool, id func(T) string) finder {
+	if c.retry.MaxAttempts < 2 {
+		return nil
+	}
+	existing := map[string]bool{}
+	for item, err := range items(ctx) {
+		if err != nil {
+			return nil
+		}
+		if match(item) {
+			existing[id(item)] = true
+		}
+	}
+	return findFirst(items, func(item T) bool {
+		return match(item) && !existing[id(item)]
+	})
+}
+
+// create performs a call which creates a resource. The request carries an
+// idempotency key, which allows it to be retried. Before it is retried, find
+// is used to check whether an earlier attempt created the resource. find
+// may be nil for resources which can't be found by name.
+func (c *Client) create(ctx context.Context, operation, path string, in, out interface{}, find finder) error {
+	req := &Request{
+		Operation: operation,
+		Method:    http.MethodPost,
+		Path:      path,
+		Body:      in,
+		Header:    http.Header{IdempotencyKeyHeader: {idempotencyKey(ctx)}},
+		find:      find,
+	}
+	return c.exec(ctx, req, out)
+}
+
+// found returns a response for a resource found by a finder, as though it
+// was returned by the Control API.
+func found(v interface{}) (*http.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
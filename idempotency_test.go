@@ -0,0 +1,232 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newKeyServer returns a server which creates keys, failing the first
+// create request with statusCode after creating the key.
+func newKeyServer(t *testing.T, statusCode int) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var keys []string
+	var idempotencyKeys []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case http.MethodGet:
+			w.Write([]byte("["))
+			for i, name := range keys {
+				if i > 0 {
+					w.Write([]byte(","))
+				}
+				fmt.Fprintf(w, `{"id":"key%d","name":%q,"created":%d}`, i, name, time.Now().UnixMilli())
+			}
+			w.Write([]byte("]"))
+		case http.MethodPost:
+			idempotencyKeys = append(idempotencyKeys, req.Header.Get(IdempotencyKeyHeader))
+			keys = append(keys, "test")
+			if len(keys) == 1 && statusCode != 0 {
+				w.WriteHeader(statusCode)
+				fmt.Fprintf(w, `{"message":"failed","code":%d,"statusCode":%d}`, statusCode*100, statusCode)
+				return
+			}
+			fmt.Fprintf(w, `{"id":"key%d","name":"test"}`, len(keys)-1)
+		}
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, &idempotencyKeys
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	srv, idempotencyKeys := newKeyServer(t, 0)
+	client, err := NewClientWithAccountID("token", "acc", WithURL(srv.URL))
+	assert.NoError(t, err)
+
+	_, err = client.CreateKey(ctx, "app", &NewKey{Name: "test"})
+	assert.NoError(t, err)
+	_, err = client.CreateKey(ctx, "app", &NewKey{Name: "test"})
+	assert.NoError(t, err)
+	_, err = client.CreateKey(ContextWithIdempotencyKey(ctx, "my-key"), "app", &NewKey{Name: "test"})
+	assert.NoError(t, err)
+
+	assert.Len(t, *idempotencyKeys, 3)
+	assert.Len(t, (*idempotencyKeys)[0], 32)
+	assert.NotEqual(t, (*idempotencyKeys)[0], (*idempotencyKeys)[1])
+	assert.Equal(t, "my-key", (*idempotencyKeys)[2])
+}
+
+func TestCreateRetryFindsCreated(t *testing.T) {
+	ctx := context.Background()
+	srv, idempotencyKeys := newKeyServer(t, http.StatusBadGateway)
+	client, err := NewClientWithAccountID("token", "acc",
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	assert.NoError(t, err)
+
+	key, err := client.CreateKey(ctx, "app", &NewKey{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "key0", key.ID)
+	assert.Len(t, *idempotencyKeys, 1)
+}
+
+func TestCreateRetryAfterTransportError(t *testing.T) {
+	ctx := context.Background()
+	srv, idempotencyKeys := newKeyServer(t, 0)
+	var posts int
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if req.Method == http.MethodPost {
+			posts++
+			if posts == 1 {
+				res.Body.Close()
+				return nil, errors.New("connection reset")
+			}
+		}
+		return res, err
+	})
+	client, err := NewClientWithAccountID("token", "acc",
+		WithURL(srv.URL),
+		WithTransport(transport),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	assert.NoError(t, err)
+
+	key, err := client.CreateKey(ctx, "app", &NewKey{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "key0", key.ID)
+	assert.Len(t, *idempotencyKeys, 1)
+
+	// without retries the transport error is returned.
+	posts = 0
+	client, err = NewClientWithAccountID("token", "acc", WithURL(srv.URL), WithTransport(transport))
+	assert.NoError(t, err)
+	_, err = client.CreateKey(ctx, "app", &NewKey{Name: "test"})
+	var transportErr *TransportError
+	assert.ErrorAs(t, err, &transportErr)
+}
+
+func TestCreateRetryRepeatsIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	var idempotencyKeys []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		idempotencyKeys = append(idempotencyKeys, req.Header.Get(IdempotencyKeyHeader))
+		w.Header().Set("Content-Type", "application/json")
+		if len(idempotencyKeys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"unavailable","code":50300,"statusCode":503}`))
+			return
+		}
+		w.Write([]byte(`{"id":"rule","ruleType":"http","target":{}}`))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// rules can't be looked up, so creating one is only retried if POST
+	// requests may be.
+	client, err := NewClientWithAccountID("token", "acc",
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	assert.NoError(t, err)
+	_, err = client.CreateRule(ctx, "app", &NewRule{Target: &HttpTarget{Url: "https://example.com"}})
+	var errorInfo ErrorInfo
+	assert.ErrorAs(t, err, &errorInfo)
+	assert.Equal(t, http.StatusServiceUnavailable, errorInfo.StatusCode)
+	assert.Len(t, idempotencyKeys, 1)
+
+	// other POST requests are still not retried.
+	idempotencyKeys = nil
+	err = client.RevokeKey(ctx, "app", "key")
+	assert.Error(t, err)
+	assert.Equal(t, []string{""}, idempotencyKeys)
+
+	idempotencyKeys = nil
+	client, err = NewClientWithAccountID("token", "acc",
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, RetryPOST: true}),
+	)
+	assert.NoError(t, err)
+	rule, err := client.CreateRule(ctx, "app", &NewRule{Target: &HttpTarget{Url: "https://example.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "rule", rule.ID)
+	assert.Len(t, idempotencyKeys, 2)
+	assert.NotEmpty(t, idempotencyKeys[0])
+	assert.Equal(t, idempotencyKeys[0], idempotencyKeys[1])
+}
+
+// newConflictServer returns a server which lists existing, fails the first
+// create request with a 503 without creating anything, and rejects later
+// ones with a 409 because the resource already exists.
+func newConflictServer(t *testing.T, existing string) (*httptest.Server, *int) {
+	var posts int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case http.MethodGet:
+			w.Write([]byte("[" + existing + "]"))
+		case http.MethodPost:
+			posts++
+			if posts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"message":"unavailable","code":50300,"statusCode":503}`))
+				return
+			}
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message":"already exists","code":40900,"statusCode":409}`))
+		}
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, &posts
+}
+
+// TestCreateRetryIgnoresExisting tests that a resource which existed before
+// a create call is not mistaken for one created by an earlier attempt.
+func TestCreateRetryIgnoresExisting(t *testing.T) {
+	ctx := context.Background()
+	for name, test := range map[string]struct {
+		existing string
+		create   func(client Client) (string, error)
+	}{
+		"app": {`{"id":"old","name":"test"}`, func(client Client) (string, error) {
+			app, err := client.CreateApp(ctx, &NewApp{Name: "test"})
+			return app.ID, err
+		}},
+		"queue": {`{"id":"app:us-east-1-a:test","name":"test","region":"us-east-1-a"}`, func(client Client) (string, error) {
+			queue, err := client.CreateQueue(ctx, "app", &NewQueue{Name: "test", Region: UsEast1A})
+			return queue.ID, err
+		}},
+		"namespace": {`{"id":"test"}`, func(client Client) (string, error) {
+			namespace, err := client.CreateNamespace(ctx, "app", &Namespace{ID: "test"})
+			return namespace.ID, err
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv, posts := newConflictServer(t, test.existing)
+			client, err := NewClientWithAccountID("token", "acc",
+				WithURL(srv.URL),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+			)
+			assert.NoError(t, err)
+
+			id, err := test.create(client)
+			assert.ErrorIs(t, err, ErrConflict)
+			assert.Empty(t, id)
+			assert.Equal(t, 2, *posts)
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package control
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 )
 
 type NewIngressRuleNoJson NewIngressRule
@@ -105,6 +107,17 @@ func (r *NewIngressRule) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&raw)
 }
 
+// UnmarshalJSON decodes an ingress rule in the format it is sent to the
+// Control API, which includes the rule type.
+func (r *NewIngressRule) UnmarshalJSON(data []byte) error {
+	var rule IngressRule
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	*r = NewIngressRule{Status: rule.Status, Target: rule.Target}
+	return nil
+}
+
 // IngressMongoTarget is the type used for MongoDB Ingress rules.
 type IngressMongoTarget struct {
 	// The URL of the MongoDB server.
@@ -164,35 +177,44 @@ func (s *IngressPostgresOutboxTarget) TargetType() string {
 }
 
 // Creates an Ingress rule for the application with the specified application ID.
-func (c *Client) CreateIngressRule(appID string, rule *NewIngressRule) (IngressRule, error) {
+//
+// Rules have no name, so an earlier attempt can't be looked up, and the call
+// is only retried if the retry policy's RetryPOST is set. The request still
+// carries an idempotency key.
+func (c *Client) CreateIngressRule(ctx context.Context, appID string, rule *NewIngressRule) (IngressRule, error) {
 	var out IngressRule
-	err := c.request("POST", "/apps/"+appID+"/rules", &rule, &out)
+	err := c.create(ctx, "CreateIngressRule", "/apps/"+appID+"/rules", rule, &out, nil)
 	return out, err
 }
 
 // Lists the rules for the application specified by the application ID.
-func (c *Client) IngressRules(appID string) ([]IngressRule, error) {
-	var rules []IngressRule
-	err := c.request("GET", "/apps/"+appID+"/rules", nil, &rules)
-	return rules, err
+func (c *Client) IngressRules(ctx context.Context, appID string) ([]IngressRule, error) {
+	return collect(c.AllIngressRules(ctx, appID))
+}
+
+// AllIngressRules returns an iterator over the ingress rules for the
+// application specified by the application ID, which fetches further pages
+// as it is consumed.
+func (c *Client) AllIngressRules(ctx context.Context, appID string) iter.Seq2[IngressRule, error] {
+	return list[IngressRule](ctx, c, "IngressRules", "/apps/"+appID+"/rules")
 }
 
 // Returns the ingess rule specified by the rule ID, for the application specified by application ID.
-func (c *Client) IngressRule(appID, ruleID string) (IngressRule, error) {
+func (c *Client) IngressRule(ctx context.Context, appID, ruleID string) (IngressRule, error) {
 	var rule IngressRule
-	err := c.request("GET", "/apps/"+appID+"/rules/"+ruleID, nil, &rule)
+	err := c.request(ctx, "IngressRule", "GET", "/apps/"+appID+"/rules/"+ruleID, nil, &rule)
 	return rule, err
 }
 
 // Updates the rule specified by the rule ID, for the application specified by application ID.
-func (c *Client) UpdateIngressRule(appID, ruleID string, rule *NewIngressRule) (IngressRule, error) {
+func (c *Client) UpdateIngressRule(ctx context.Context, appID, ruleID string, rule *NewIngressRule) (IngressRule, error) {
 	var out IngressRule
-	err := c.request("PATCH", "/apps/"+appID+"/rules/"+ruleID, &rule, &out)
+	err := c.request(ctx, "UpdateIngressRule", "PATCH", "/apps/"+appID+"/rules/"+ruleID, rule, &out)
 	return out, err
 }
 
 // Deletes the rule specified by the rule ID, for the application specified by application ID.
-func (c *Client) DeleteIngressRule(appID, ruleID string) error {
-	err := c.request("DELETE", "/apps/"+appID+"/rules/"+ruleID, nil, nil)
+func (c *Client) DeleteIngressRule(ctx context.Context, appID, ruleID string) error {
+	err := c.request(ctx, "DeleteIngressRule", "DELETE", "/apps/"+appID+"/rules/"+ruleID, nil, nil)
 	return err
 }
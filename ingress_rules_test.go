@@ -1,6 +1,7 @@
 package control
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,6 +37,7 @@ func TestRuleIngressPostgresOutbox(t *testing.T) {
 }
 
 func testIngressRule(t *testing.T, target IngressTarget) {
+	ctx := context.Background()
 	client, _ := newTestClient(t)
 	app := newTestApp(t, &client)
 
@@ -44,7 +46,7 @@ func testIngressRule(t *testing.T, target IngressTarget) {
 		Target: target,
 	}
 
-	r, err := client.CreateIngressRule(app.ID, &rule)
+	r, err := client.CreateIngressRule(ctx, app.ID, &rule)
 	assert.NoError(t, err)
 	assert.Equal(t, rule.Target, r.Target)
 	assert.Equal(t, rule.Target.TargetType(), r.Target.TargetType())
@@ -55,13 +57,13 @@ func testIngressRule(t *testing.T, target IngressTarget) {
 	assert.NotEmpty(t, r.Created)
 	assert.NotEmpty(t, r.Modified)
 
-	r2, err := client.IngressRule(app.ID, r.ID)
+	r2, err := client.IngressRule(ctx, app.ID, r.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, r, r2)
 
-	err = client.DeleteIngressRule(app.ID, r.ID)
+	err = client.DeleteIngressRule(ctx, app.ID, r.ID)
 	assert.NoError(t, err)
 
-	err = client.DeleteApp(app.ID)
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
 }
@@ -0,0 +1,112 @@
+// Package redact removes secrets from Control API request and response
+// bodies, so that they can be logged or stored.
+package redact
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "[REDACTED]"
+
+// secretFields are the names of JSON fields whose string values are
+// secrets, wherever they appear in a document.
+var secretFields = map[string]bool{
+	// Key.Key
+	"key": true,
+	// NewApp.ApnsPrivateKey, NewApp.FcmKey and NewApp.FcmServiceAccount
+	"apnsPrivateKey":    true,
+	"fcmKey":            true,
+	"fcmServiceAccount": true,
+	// Sasl.Password
+	"password": true,
+	// AuthenticationModeCredentials.SecretAccessKey
+	"secretAccessKey": true,
+	// PulsarAuthentication.Token
+	"token": true,
+	// HttpIftttTarget.WebhookKey
+	"webhookKey": true,
+}
+
+// urlFields are the names of JSON fields whose string values are URLs which
+// may contain credentials, such as IngressMongoTarget.Url.
+var urlFields = map[string]bool{
+	"url": true,
+}
+
+// JSON returns a copy of the JSON document data with secrets redacted. If
+// data is not valid JSON it is replaced by a placeholder, since it can't be
+// known what it contains.
+func JSON(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(`"` + Placeholder + `"`)
+	}
+	out, err := json.Marshal(Value(v))
+	if err != nil {
+		return []byte(`"` + Placeholder + `"`)
+	}
+	return out
+}
+
+// Text returns a placeholder for text which is not JSON, such as an error
+// page, since it can't be known what it contains.
+func Text(text string) string {
+	if text == "" {
+		return ""
+	}
+	return Placeholder
+}
+
+// Value redacts secrets in place in a value decoded from JSON into an
+// interface{}, and returns it.
+func Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			s, isString := field.(string)
+			switch {
+			case isString && s != "" && secretFields[k]:
+				v[k] = Placeholder
+			case isString && urlFields[k]:
+				v[k] = URL(s)
+			default:
+				v[k] = Value(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = Value(v[i])
+		}
+	}
+	return v
+}
+
+// URL returns rawURL with any password in it redacted.
+func URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Placeholder
+	}
+	if _, ok := u.User.Password(); !ok {
+		return rawURL
+	}
+	u.User = url.UserPassword(u.User.Username(), Placeholder)
+	return u.String()
+}
+
+// Authorization returns the value of an Authorization header with the
+// credentials redacted.
+func Authorization(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) > 7 && value[:7] == "Bearer " {
+		return "Bearer " + Placeholder
+	}
+	return Placeholder
+}
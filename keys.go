@@ -1,5 +1,11 @@
 package control
 
+import (
+	"context"
+	"iter"
+	"time"
+)
+
 // A struct representing an Ably Key.
 type Key struct {
 	// The key ID.
@@ -36,29 +42,73 @@ type NewKey struct {
 	RevocableTokens bool `json:"revocableTokens"`
 }
 
+// KeyUpdate is used to update an Ably key. Only the fields which are not
+// nil are changed.
+type KeyUpdate struct {
+	// The name for your API key. This is a friendly name for your reference.
+	Name *string `json:"name,omitempty"`
+	// The capabilities that this key has. More information on capabilities
+	// can be found in the Ably documentation https://ably.com/documentation/core-features/authentication#capabilities-explained.
+	Capability map[string][]string `json:"capability,omitempty"`
+	// Enable Revocable Tokens. More information on Token Revocation can be
+	// found in the Ably documentation https://ably.com/docs/auth/revocation
+	RevocableTokens *bool `json:"revocableTokens,omitempty"`
+}
+
 // Keys lists the API keys associated with the application ID.
-func (c *Client) Keys(appID string) ([]Key, error) {
-	var keys []Key
-	err := c.request("GET", "/apps/"+appID+"/keys", nil, &keys)
-	return keys, err
+func (c *Client) Keys(ctx context.Context, appID string) ([]Key, error) {
+	return collect(c.AllKeys(ctx, appID))
+}
+
+// AllKeys returns an iterator over the API keys associated with the
+// application ID, which fetches further pages as it is consumed.
+func (c *Client) AllKeys(ctx context.Context, appID string) iter.Seq2[Key, error] {
+	return list[Key](ctx, c, "Keys", "/apps/"+appID+"/keys")
+}
+
+// Key fetches the API key with the specified key ID. An error matching
+// ErrNotFound is returned if there is no such key.
+func (c *Client) Key(ctx context.Context, appID, keyID string) (Key, error) {
+	return findByID(c.AllKeys(ctx, appID), "key", "/apps/"+appID+"/keys", keyID, func(k Key) string { return k.ID })
+}
+
+// FindKeyByName fetches the API key with the specified name. An error
+// matching ErrNotFound is returned if there is no such key, or one matching
+// ErrAmbiguous if more than one key has the name.
+func (c *Client) FindKeyByName(ctx context.Context, appID, name string) (Key, error) {
+	return findByName(c.AllKeys(ctx, appID), "key", "/apps/"+appID+"/keys", name,
+		func(k Key) string { return k.Name },
+		func(k Key) string { return k.ID },
+	)
 }
 
 // CreateKey creates an application with the specified properties.
-func (c *Client) CreateKey(appID string, key *NewKey) (Key, error) {
+//
+// If the call is retried, an enabled key with the same name created since
+// the call started is assumed to have been created by an earlier attempt.
+func (c *Client) CreateKey(ctx context.Context, appID string, key *NewKey) (Key, error) {
 	var out Key
-	err := c.request("POST", "/apps/"+appID+"/keys", &key, &out)
+	since := time.Now().Add(-createdKeySkew).UnixMilli()
+	keys := func(ctx context.Context) iter.Seq2[Key, error] {
+		return c.AllKeys(ctx, appID)
+	}
+	find := findFirst(keys, func(k Key) bool {
+		return k.Name == key.Name && k.Status == 0 && int64(k.Created) >= since
+	})
+	err := c.create(ctx, "CreateKey", "/apps/"+appID+"/keys", key, &out, find)
 	return out, err
 }
 
-// UpdateKey updates the API key with the specified key ID.
-func (c *Client) UpdateKey(appID, keyID string, key *NewKey) (Key, error) {
+// UpdateKey updates the API key with the specified key ID. Only the fields
+// of key which are set are changed.
+func (c *Client) UpdateKey(ctx context.Context, appID, keyID string, key *KeyUpdate) (Key, error) {
 	var out Key
-	err := c.request("PATCH", "/apps/"+appID+"/keys/"+keyID, &key, &out)
+	err := c.request(ctx, "UpdateKey", "PATCH", "/apps/"+appID+"/keys/"+keyID, key, &out)
 	return out, err
 }
 
 // RevokeKey revokes the API key with the specified ID. This deletes the key.
-func (c *Client) RevokeKey(appID, keyID string) error {
-	err := c.request("POST", "/apps/"+appID+"/keys/"+keyID+"/revoke", nil, nil)
+func (c *Client) RevokeKey(ctx context.Context, appID, keyID string) error {
+	err := c.request(ctx, "RevokeKey", "POST", "/apps/"+appID+"/keys/"+keyID+"/revoke", nil, nil)
 	return err
 }
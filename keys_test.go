@@ -1,6 +1,7 @@
 package control
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -9,6 +10,7 @@ import (
 )
 
 func TestKeys(t *testing.T) {
+	ctx := context.Background()
 	client, _ := newTestClient(t)
 	app := newTestApp(t, &client)
 
@@ -20,7 +22,7 @@ func TestKeys(t *testing.T) {
 		RevocableTokens: true,
 	}
 
-	k, err := client.CreateKey(app.ID, &key)
+	k, err := client.CreateKey(ctx, app.ID, &key)
 	assert.NoError(t, err)
 	assert.Equal(t, key.Name, k.Name)
 	assert.Equal(t, key.Capability, k.Capability)
@@ -32,25 +34,64 @@ func TestKeys(t *testing.T) {
 	assert.NotEmpty(t, k.ID)
 	assert.NotEmpty(t, k.Key)
 
-	keys, err := client.Keys(app.ID)
+	keys, err := client.Keys(ctx, app.ID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, keys)
 
-	key = NewKey{
-		Name:            name + "-changed",
+	update := KeyUpdate{
+		Name:            String(name + "-changed"),
 		Capability:      map[string][]string{"b": {"publish"}},
-		RevocableTokens: false,
+		RevocableTokens: Bool(false),
 	}
 
-	k, err = client.UpdateKey(app.ID, k.ID, &key)
+	k, err = client.UpdateKey(ctx, app.ID, k.ID, &update)
 	assert.NoError(t, err)
-	assert.Equal(t, key.Name, k.Name)
-	assert.Equal(t, key.Capability, k.Capability)
-	assert.Equal(t, key.RevocableTokens, k.RevocableTokens)
+	assert.Equal(t, *update.Name, k.Name)
+	assert.Equal(t, update.Capability, k.Capability)
+	assert.False(t, k.RevocableTokens)
+
+	k, err = client.UpdateKey(ctx, app.ID, k.ID, &KeyUpdate{Name: String(name)})
+	assert.NoError(t, err)
+	assert.Equal(t, name, k.Name)
+	assert.Equal(t, update.Capability, k.Capability)
+
+	err = client.RevokeKey(ctx, app.ID, k.ID)
+	assert.NoError(t, err)
+
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestKeyLookup(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	key := NewKey{
+		Name:       "test-key-" + fmt.Sprint(rand.Uint64()),
+		Capability: map[string][]string{"a": {"subscribe"}},
+	}
+	k1, err := client.CreateKey(ctx, app.ID, &key)
+	assert.NoError(t, err)
+
+	k, err := client.Key(ctx, app.ID, k1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, k1.ID, k.ID)
+	k, err = client.FindKeyByName(ctx, app.ID, key.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, k1.ID, k.ID)
+
+	_, err = client.Key(ctx, app.ID, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
 
-	err = client.RevokeKey(app.ID, k.ID)
+	k2, err := client.CreateKey(ctx, app.ID, &key)
 	assert.NoError(t, err)
+	_, err = client.FindKeyByName(ctx, app.ID, key.Name)
+	assert.ErrorIs(t, err, ErrAmbiguous)
+	var ambiguous *AmbiguousNameError
+	assert.ErrorAs(t, err, &ambiguous)
+	assert.ElementsMatch(t, []string{k1.ID, k2.ID}, ambiguous.IDs)
 
-	err = client.DeleteApp(app.ID)
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
 }
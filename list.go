@@ -0,0 +1,185 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// list returns an iterator over the elements of the JSON array returned by
+// a GET request to path.
+//
+// If the response has a Link header with a "next" relation, the elements of
+// the linked pages are returned after it. Each page is decoded as it is
+// read, rather than being buffered in memory. The iterator stops after the
+// first error.
+func list[T any](ctx context.Context, c *Client, operation, path string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		// path is copied, so that the sequence can be ranged over again.
+		next := path
+		for next != "" {
+			var err error
+			next, err = listPage(ctx, c, operation, next, yield)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+		}
+	}
+}
+
+// listPage yields the elements of a single page, and returns the path of
+// the next page or an empty string if there are no more pages or yield
+// returned false.
+func listPage[T any](ctx context.Context, c *Client, operation, path string, yield func(T, error) bool) (string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	res, err := c.call(ctx, &Request{Operation: operation, Method: "GET", Path: path})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	next, err := c.nextPage(res.Header, path)
+	if err != nil {
+		return "", err
+	}
+
+	dec := json.NewDecoder(res.Body)
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	if tok == nil {
+		// a null response has no elements.
+		return next, nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return "", fmt.Errorf("%s: expected a JSON array, got %v", path, tok)
+	}
+	for dec.More() {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return "", err
+		}
+		if !yield(v, nil) {
+			return "", nil
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// nextPage returns the API path of the page linked from a response's Link
+// header with a "next" relation, or an empty string if there is no such
+// link. Links must point at the Client's REST API, so that the access
+// token is never sent elsewhere.
+func (c *Client) nextPage(header http.Header, path string) (string, error) {
+	link := nextLink(header)
+	if link == "" {
+		return "", nil
+	}
+	base, err := neturl.Parse(c.Url + path)
+	if err != nil {
+		return "", err
+	}
+	ref, err := neturl.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid Link header: %w", path, err)
+	}
+	next := base.ResolveReference(ref).String()
+	if !strings.HasPrefix(next, c.Url+"/") {
+		return "", fmt.Errorf("%s: Link header points outside of %s: %s", path, c.Url, next)
+	}
+	return strings.TrimPrefix(next, c.Url), nil
+}
+
+// nextLink returns the target of the link with a "next" relation in a Link
+// header, such as `<./apps?cursor=abc>; rel="next"`.
+func nextLink(header http.Header) string {
+	for _, v := range header.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			target, params, _ := strings.Cut(link, ";")
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// collect returns the elements of an iterator as a slice, stopping at the
+// first error.
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var s []T
+	for v, err := range seq {
+		if err != nil {
+			return s, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+// findByID returns the element of seq with the given ID, or a not found
+// ErrorInfo for path if there is none.
+func findByID[T any](seq iter.Seq2[T, error], resource, path, id string, idOf func(T) string) (T, error) {
+	for v, err := range seq {
+		if err != nil {
+			return v, err
+		}
+		if idOf(v) == id {
+			return v, nil
+		}
+	}
+	var zero T
+	return zero, notFound(resource, id, path)
+}
+
+// findByName returns the only element of seq with the given name. It
+// returns a not found ErrorInfo for path if there is none, or an
+// *AmbiguousNameError if there is more than one.
+func findByName[T any](seq iter.Seq2[T, error], resource, path, name string, nameOf, idOf func(T) string) (T, error) {
+	var found []T
+	for v, err := range seq {
+		if err != nil {
+			return v, err
+		}
+		if nameOf(v) == name {
+			found = append(found, v)
+		}
+	}
+	var zero T
+	switch len(found) {
+	case 0:
+		return zero, notFound(resource, name, path)
+	case 1:
+		return found[0], nil
+	}
+	ids := make([]string, len(found))
+	for i, v := range found {
+		ids[i] = idOf(v)
+	}
+	return zero, &AmbiguousNameError{Resource: resource, Name: name, IDs: ids}
+}
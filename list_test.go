@@ -0,0 +1,90 @@
+package control
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newListTestServer starts a test HTTP server which returns the keys of an
+// app in pages of two, linked with Link headers.
+func newListTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	var requests []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.URL.RequestURI())
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Query().Get("cursor") {
+		case "":
+			w.Header().Set("Link", `</apps/app/keys?cursor=2>; rel="next"`)
+			w.Write([]byte(`[{"id":"k1"},{"id":"k2"}]`))
+		case "2":
+			w.Header().Add("Link", `<./keys?cursor=1>; rel="first"`)
+			w.Header().Add("Link", `<./keys?cursor=3>; rel="next"`)
+			w.Write([]byte(`[{"id":"k3"},{"id":"k4"}]`))
+		case "3":
+			w.Write([]byte(`[{"id":"k5"}]`))
+		case "evil":
+			w.Header().Set("Link", `<https://example.com/keys>; rel="next"`)
+			w.Write([]byte(`[]`))
+		}
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	srv, requests := newListTestServer(t)
+	client, err := NewClientWithAccountID("s3cr3t", "acc", WithURL(srv.URL))
+	assert.NoError(t, err)
+
+	keys, err := client.Keys(ctx, "app")
+	assert.NoError(t, err)
+	var ids []string
+	for _, k := range keys {
+		ids = append(ids, k.ID)
+	}
+	assert.Equal(t, []string{"k1", "k2", "k3", "k4", "k5"}, ids)
+	assert.Equal(t, []string{"/apps/app/keys", "/apps/app/keys?cursor=2", "/apps/app/keys?cursor=3"}, *requests)
+
+	// stopping early doesn't fetch further pages.
+	*requests = nil
+	for k, err := range client.AllKeys(ctx, "app") {
+		assert.NoError(t, err)
+		assert.Equal(t, "k1", k.ID)
+		break
+	}
+	assert.Equal(t, []string{"/apps/app/keys"}, *requests)
+
+	// the same sequence can be ranged over more than once.
+	seq := client.AllKeys(ctx, "app")
+	for range 2 {
+		ids = nil
+		for k, err := range seq {
+			assert.NoError(t, err)
+			ids = append(ids, k.ID)
+		}
+		assert.Equal(t, []string{"k1", "k2", "k3", "k4", "k5"}, ids)
+	}
+}
+
+func TestListForeignLink(t *testing.T) {
+	ctx := context.Background()
+	srv, _ := newListTestServer(t)
+	client, err := NewClientWithAccountID("s3cr3t", "acc", WithURL(srv.URL))
+	assert.NoError(t, err)
+
+	_, err = collect(list[Key](ctx, &client, "Keys", "/apps/app/keys?cursor=evil"))
+	assert.ErrorContains(t, err, "Link header points outside of")
+}
+
+func TestNextLink(t *testing.T) {
+	assert.Equal(t, "/a?b=c", nextLink(http.Header{"Link": {`</first>; rel="first", </a?b=c>; rel="next"`}}))
+	assert.Equal(t, "/a", nextLink(http.Header{"Link": {`</a>; title="x"; rel="last next"`}}))
+	assert.Equal(t, "", nextLink(http.Header{"Link": {`</a>; rel="prev"`}}))
+	assert.Equal(t, "", nextLink(http.Header{}))
+}
@@ -0,0 +1,126 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ably/ably-control-go/internal/redact"
+)
+
+// LogLevels are the levels at which a Client logs requests.
+type LogLevels struct {
+	// The level for requests which succeed.
+	Success slog.Level
+	// The level for requests which fail, including attempts which are
+	// retried.
+	Failure slog.Level
+	// The level for an extra record containing the request and response
+	// headers and bodies. Secrets in them are redacted.
+	Body slog.Level
+}
+
+// DefaultLogLevels are the levels used unless WithLogLevels is given.
+var DefaultLogLevels = LogLevels{
+	Success: slog.LevelDebug,
+	Failure: slog.LevelWarn,
+	Body:    slog.LevelDebug - 4,
+}
+
+// WithLogger logs every request made by the Client, including each retry,
+// with its method, path, status code, duration, attempt and Ably error
+// code. The access token is never logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLogLevels sets the levels at which requests are logged.
+func WithLogLevels(levels LogLevels) Option {
+	return func(o *options) {
+		o.logLevels = &levels
+	}
+}
+
+// logAttempt logs a single attempt at a request, which either resulted in
+// res or failed with err. If the body of res is logged it is replaced, so
+// that it can still be read.
+func (c *Client) logAttempt(ctx context.Context, req *Request, body []byte, attempt int, start time.Time, res *http.Response, err error) {
+	if c.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.Path),
+		slog.Int("attempt", attempt),
+		slog.Duration("duration", time.Since(start)),
+	}
+	level := c.logLevels.Success
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+	}
+	if errorInfo, ok := err.(ErrorInfo); ok {
+		level = c.logLevels.Failure
+		message := errorInfo.Message
+		if errorInfo.notJSON {
+			message = redact.Text(message)
+		}
+		attrs = append(attrs, slog.Int("code", errorInfo.Code), slog.String("error", message))
+	} else if err != nil {
+		level = c.logLevels.Failure
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	c.logger.LogAttrs(ctx, level, "control api request", attrs...)
+
+	if !c.logger.Enabled(ctx, c.logLevels.Body) {
+		return
+	}
+	// log the headers which were sent if possible, rather than just those
+	// set by middleware.
+	reqHeader := req.Header
+	if res != nil && res.Request != nil {
+		reqHeader = res.Request.Header
+	}
+	attrs = []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.Path),
+		slog.Int("attempt", attempt),
+		slog.Any("requestHeader", redactHeader(reqHeader)),
+		slog.String("requestBody", string(redact.JSON(body))),
+	}
+	if res != nil && err == nil {
+		resBody, readErr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(io.MultiReader(bytes.NewReader(resBody), errReader{readErr}))
+		attrs = append(attrs,
+			slog.Any("responseHeader", redactHeader(res.Header)),
+			slog.String("responseBody", string(redact.JSON(resBody))),
+		)
+	}
+	c.logger.LogAttrs(ctx, c.logLevels.Body, "control api request body", attrs...)
+}
+
+// redactHeader returns a copy of header with credentials redacted.
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	if v := header.Get("Authorization"); v != "" {
+		header.Set("Authorization", redact.Authorization(v))
+	}
+	return header
+}
+
+// errReader returns err from every call to Read, or io.EOF if err is nil.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	if r.err == nil {
+		return 0, io.EOF
+	}
+	return 0, r.err
+}
@@ -0,0 +1,97 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	ctx := context.Background()
+	var requests int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"unavailable","code":50300,"statusCode":503}`))
+			return
+		}
+		w.Write([]byte(`{"id":"k","name":"test","key":"app.k:s3cr3t-key"}`))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: DefaultLogLevels.Body}))
+	client, err := NewClientWithAccountID("s3cr3t-token", "acc",
+		WithURL(srv.URL),
+		WithLogger(logger),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+	)
+	assert.NoError(t, err)
+
+	key, err := client.UpdateKey(ctx, "app", "k", &KeyUpdate{Name: String("test")})
+	assert.NoError(t, err)
+	assert.Equal(t, "app.k:s3cr3t-key", key.Key)
+
+	out := buf.String()
+	assert.NotContains(t, out, "s3cr3t")
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	assert.Len(t, records, 4)
+
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "PATCH", records[0]["method"])
+	assert.Equal(t, "/apps/app/keys/k", records[0]["path"])
+	assert.Equal(t, 503.0, records[0]["status"])
+	assert.Equal(t, 50300.0, records[0]["code"])
+	assert.Equal(t, 1.0, records[0]["attempt"])
+
+	assert.Equal(t, "DEBUG", records[2]["level"])
+	assert.Equal(t, 200.0, records[2]["status"])
+	assert.Equal(t, 2.0, records[2]["attempt"])
+
+	assert.Equal(t, `{"id":"k","key":"[REDACTED]","name":"test"}`, records[3]["responseBody"])
+	assert.Equal(t, []interface{}{"Bearer [REDACTED]"}, records[3]["requestHeader"].(map[string]interface{})["Authorization"])
+}
+
+// TestLoggerNonJSONError tests that the body of an error response which is
+// not JSON is not logged, as it may echo secrets from the request.
+func TestLoggerNonJSONError(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`<html>bad gateway: password=s3cr3t</html>`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client, err := NewClientWithAccountID("token", "acc", WithURL(srv.URL), WithLogger(logger))
+	assert.NoError(t, err)
+
+	_, err = client.Keys(ctx, "app")
+	var errorInfo ErrorInfo
+	assert.ErrorAs(t, err, &errorInfo)
+	assert.Equal(t, http.StatusBadGateway, errorInfo.StatusCode)
+
+	out := buf.String()
+	assert.NotContains(t, out, "s3cr3t")
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(out)), &record))
+	assert.Equal(t, "[REDACTED]", record["error"])
+}
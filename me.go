@@ -1,5 +1,7 @@
 package control
 
+import "context"
+
 // The Me struct contains information about the token the current user authenticates with.
 type Me struct {
 	// The access token used to authenticate.
@@ -37,9 +39,9 @@ type Account struct {
 }
 
 // Me fetches information about the token the current user authenticates with.
-func (c *Client) Me() (Me, error) {
+func (c *Client) Me(ctx context.Context) (Me, error) {
 	var me Me
-	err := c.request("GET", "/me", nil, &me)
+	err := c.request(ctx, "Me", "GET", "/me", nil, &me)
 	if err != nil {
 		return me, err
 	}
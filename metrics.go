@@ -0,0 +1,73 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Metrics receives an observation for every call made by a Client.
+//
+// ObserveRequest is called from the goroutine making the call, and must be
+// safe for concurrent use. The controlprom package provides an
+// implementation which records Prometheus metrics.
+type Metrics interface {
+	ObserveRequest(ctx context.Context, observation RequestObservation)
+}
+
+// RequestObservation describes a completed call to the Control API.
+type RequestObservation struct {
+	// The name of the Client method which made the call, such as
+	// "CreateApp".
+	Operation string
+	// The HTTP method, such as "GET" or "POST".
+	Method string
+	// The number of requests sent, which is more than one if the call was
+	// retried.
+	Attempts int
+	// The time taken by the call, including any retries.
+	Duration time.Duration
+	// The HTTP status code of the last response, or 0 if no response was
+	// received.
+	StatusCode int
+	// The Ably error code if the call failed with an ErrorInfo, otherwise 0.
+	Code int
+	// The error the call failed with, or nil if it succeeded.
+	Err error
+}
+
+// Retries returns the number of times the call was retried.
+func (o RequestObservation) Retries() int {
+	if o.Attempts <= 1 {
+		return 0
+	}
+	return o.Attempts - 1
+}
+
+// WithMetrics records every call made by the Client with metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// observe records a call to metrics, if the Client has any.
+func (c *Client) observe(ctx context.Context, req *Request, start time.Time, attempts, statusCode int, err error) {
+	if c.metrics == nil {
+		return
+	}
+	observation := RequestObservation{
+		Operation:  req.Operation,
+		Method:     req.Method,
+		Attempts:   attempts,
+		Duration:   time.Since(start),
+		StatusCode: statusCode,
+		Err:        err,
+	}
+	var errorInfo ErrorInfo
+	if errors.As(err, &errorInfo) {
+		observation.StatusCode = errorInfo.StatusCode
+		observation.Code = errorInfo.Code
+	}
+	c.metrics.ObserveRequest(ctx, observation)
+}
@@ -0,0 +1,67 @@
+package control
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetrics struct {
+	mu           sync.Mutex
+	observations []RequestObservation
+}
+
+func (m *testMetrics) ObserveRequest(ctx context.Context, o RequestObservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, o)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	srv, _ := newRetryTestServer(t, 1, http.StatusServiceUnavailable)
+
+	metrics := &testMetrics{}
+	client, err := NewClientWithAccountID("token", "acc",
+		WithURL(srv.URL),
+		WithMetrics(metrics),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	assert.NoError(t, err)
+
+	err = client.DeleteApp(ctx, "app")
+	assert.NoError(t, err)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"forbidden","code":40300,"statusCode":403}`))
+	}))
+	defer failing.Close()
+	client.Url = failing.URL
+	_, err = client.CreateKey(ctx, "app", &NewKey{Name: "key"})
+	assert.Error(t, err)
+
+	assert.Len(t, metrics.observations, 2)
+	deleted := metrics.observations[0]
+	assert.Equal(t, "DeleteApp", deleted.Operation)
+	assert.Equal(t, "DELETE", deleted.Method)
+	assert.Equal(t, 2, deleted.Attempts)
+	assert.Equal(t, 1, deleted.Retries())
+	assert.Equal(t, http.StatusOK, deleted.StatusCode)
+	assert.Zero(t, deleted.Code)
+	assert.NoError(t, deleted.Err)
+	assert.Positive(t, deleted.Duration)
+
+	created := metrics.observations[1]
+	assert.Equal(t, "CreateKey", created.Operation)
+	assert.Equal(t, 1, created.Attempts)
+	assert.Equal(t, 0, created.Retries())
+	assert.Equal(t, http.StatusForbidden, created.StatusCode)
+	assert.Equal(t, CodeForbidden, created.Code)
+	assert.ErrorIs(t, created.Err, ErrForbidden)
+}
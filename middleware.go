@@ -0,0 +1,68 @@
+package control
+
+import (
+	"context"
+	"net/http"
+)
+
+// Request describes a call to the Control API, as seen by Middleware.
+type Request struct {
+	// The name of the Client method which made the call, such as
+	// "CreateApp". Calls made by iterators such as AllApps are named after
+	// the equivalent method returning a slice, such as "Apps".
+	Operation string
+	// The ID of the account the Client manages, or an empty string if it
+	// is not known yet.
+	AccountID string
+	// The HTTP method, such as "GET" or "POST".
+	Method string
+	// The API path, such as "/apps/{appID}/keys".
+	Path string
+	// The value encoded as JSON in the request body, such as a *NewApp, or
+	// nil if the request has no body.
+	Body interface{}
+	// Extra HTTP headers to send with the request. The Authorization and
+	// Ably-Agent headers can not be replaced. Create calls, such as
+	// CreateApp, set the Idempotency-Key header.
+	Header http.Header
+
+	// find is set by create calls which can check whether an earlier
+	// attempt created the resource.
+	find finder
+}
+
+// idempotent reports whether the request is a create call, which has an
+// idempotency key.
+func (r *Request) idempotent() bool {
+	return r.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// Doer performs a call to the Control API.
+//
+// A successful call returns the response, whose body the caller must close.
+// An unsuccessful call returns an error, which is an ErrorInfo if the
+// Control API responded with an error.
+type Doer interface {
+	Do(ctx context.Context, req *Request) (*http.Response, error)
+}
+
+// DoerFunc allows a function to be used as a Doer.
+type DoerFunc func(ctx context.Context, req *Request) (*http.Response, error)
+
+// Do calls f(ctx, req).
+func (f DoerFunc) Do(ctx context.Context, req *Request) (*http.Response, error) {
+	return f(ctx, req)
+}
+
+// Middleware wraps every call made by a Client. It can inspect or modify
+// the request before passing it to next, inspect the response or error
+// next returns, or handle the call itself without calling next.
+type Middleware func(next Doer) Doer
+
+// WithMiddleware adds middleware which wraps every call made by the Client,
+// including any retries. The first middleware is the outermost.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
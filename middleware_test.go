@@ -0,0 +1,98 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	var header string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header.Get("X-Audit")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"key","name":"test"}`))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var calls []string
+	audit := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(ctx context.Context, req *Request) (*http.Response, error) {
+				calls = append(calls, name+" "+req.Method+" "+req.Path)
+				if req.Header == nil {
+					req.Header = make(http.Header)
+				}
+				req.Header.Add("X-Audit", name)
+				res, err := next.Do(ctx, req)
+				if err == nil {
+					calls = append(calls, name+" "+res.Status)
+				}
+				return res, err
+			})
+		}
+	}
+	var body interface{}
+	capture := func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*http.Response, error) {
+			body = req.Body
+			return next.Do(ctx, req)
+		})
+	}
+
+	client, err := NewClientWithAccountID("s3cr3t", "acc",
+		WithURL(srv.URL),
+		WithMiddleware(audit("outer"), audit("inner")),
+		WithMiddleware(capture),
+	)
+	assert.NoError(t, err)
+
+	newKey := NewKey{Name: "test"}
+	key, err := client.CreateKey(ctx, "app", &newKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "key", key.ID)
+	assert.Equal(t, []string{
+		"outer POST /apps/app/keys",
+		"inner POST /apps/app/keys",
+		"inner 200 OK",
+		"outer 200 OK",
+	}, calls)
+	assert.Equal(t, "outer", header)
+	assert.Equal(t, &newKey, body)
+}
+
+func TestMiddlewareVeto(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("mutations are not allowed")
+	readOnly := func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				return nil, errDenied
+			}
+			// respond without calling the Control API.
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`[{"id":"app","name":"fake"}]`)),
+			}, nil
+		})
+	}
+
+	client, err := NewClientWithAccountID("s3cr3t", "acc", WithURL("http://127.0.0.1:1"), WithMiddleware(readOnly))
+	assert.NoError(t, err)
+
+	apps, err := client.Apps(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []App{{ID: "app", Name: "fake"}}, apps)
+
+	err = client.DeleteApp(ctx, "app")
+	assert.ErrorIs(t, err, errDenied)
+}
@@ -1,5 +1,10 @@
 package control
 
+import (
+	"context"
+	"iter"
+)
+
 // A struct representing an Ably namespace.
 type Namespace struct {
 	//The namespace or channel name that the channel rule will apply to. For example,
@@ -45,37 +50,97 @@ type Namespace struct {
 	ConflationKey string `json:"conflationKey"`
 }
 
+// NamespaceUpdate is used to update an Ably namespace. Only the fields which
+// are not nil are changed.
+type NamespaceUpdate struct {
+	// If true, clients will not be permitted to use (including to attach, publish, or subscribe)
+	// any channels within this namespace unless they are identified.
+	Authenticated *bool `json:"authenticated,omitempty"`
+	// If true, all messages on a channel will be stored for 24 hours.
+	Persisted *bool `json:"persisted,omitempty"`
+	// If true, the last message published on a channel will be stored for 365 days.
+	PersistLast *bool `json:"persistLast,omitempty"`
+	// If true, publishing messages with a push payload in the extras field is permitted.
+	PushEnabled *bool `json:"pushEnabled,omitempty"`
+	// If true, only clients that are connected using TLS will be permitted to subscribe to any
+	// channels within this namespace.
+	TlsOnly *bool `json:"tlsOnly,omitempty"`
+	// If true, messages received on a channel will contain a unique timeserial.
+	ExposeTimeserial *bool `json:"exposeTimeserial,omitempty"`
+	// If true, channels within this namespace will start batching inbound messages.
+	BatchingEnabled *bool `json:"batchingEnabled,omitempty"`
+	// The maximium batching interval in the channel.
+	BatchingInterval *int `json:"batchingInterval,omitempty"`
+	// If true, enables conflation for channels within this namespace.
+	ConflationEnabled *bool `json:"conflationEnabled,omitempty"`
+	// The interval in milliseconds at which messages are conflated.
+	ConflationInterval *int `json:"conflationInterval,omitempty"`
+	// The key used to determine which messages should be conflated.
+	ConflationKey *string `json:"conflationKey,omitempty"`
+}
+
 // Namespaces lists the namespaces for the specified application ID.
-func (c *Client) Namespaces(appID string) ([]Namespace, error) {
-	var namespaces []Namespace
-	err := c.request("GET", "/apps/"+appID+"/namespaces", nil, &namespaces)
-	return namespaces, err
+func (c *Client) Namespaces(ctx context.Context, appID string) ([]Namespace, error) {
+	return collect(c.AllNamespaces(ctx, appID))
+}
+
+// AllNamespaces returns an iterator over the namespaces for the specified
+// application ID, which fetches further pages as it is consumed.
+func (c *Client) AllNamespaces(ctx context.Context, appID string) iter.Seq2[Namespace, error] {
+	return list[Namespace](ctx, c, "Namespaces", "/apps/"+appID+"/namespaces")
+}
+
+// Namespace fetches the namespace with the specified ID. An error matching
+// ErrNotFound is returned if there is no such namespace.
+func (c *Client) Namespace(ctx context.Context, appID, namespaceID string) (Namespace, error) {
+	return findByID(c.AllNamespaces(ctx, appID), "namespace", "/apps/"+appID+"/namespaces", namespaceID, func(n Namespace) string { return n.ID })
 }
 
 // CreateNamespace creates a namespace for the specified application ID.
-func (c *Client) CreateNamespace(appID string, namespace *Namespace) (Namespace, error) {
+//
+// If the call is retried, a new namespace with the same ID is assumed to
+// have been created by an earlier attempt. When retries are enabled the
+// app's namespaces are listed before the first attempt, so that a namespace
+// which already existed is not mistaken for it.
+func (c *Client) CreateNamespace(ctx context.Context, appID string, namespace *Namespace) (Namespace, error) {
 	var out Namespace
-	err := c.request("POST", "/apps/"+appID+"/namespaces", &namespace, &out)
+	namespaces := func(ctx context.Context) iter.Seq2[Namespace, error] {
+		return c.AllNamespaces(ctx, appID)
+	}
+	find := findNew(ctx, c, namespaces,
+		func(n Namespace) bool { return n.ID == namespace.ID },
+		func(n Namespace) string { return n.ID },
+	)
+	err := c.create(ctx, "CreateNamespace", "/apps/"+appID+"/namespaces", namespace, &out, find)
 	return out, err
 }
 
 // UpdateNamespace updates the namespace with the specified ID, for the application with the specified application ID.
-func (c *Client) UpdateNamespace(appID string, namespace *Namespace) (Namespace, error) {
-	in := *namespace
-	id := in.ID
-	in.ID = ""
-
+// Only the fields of namespace which are set are changed.
+func (c *Client) UpdateNamespace(ctx context.Context, appID, namespaceID string, namespace *NamespaceUpdate) (Namespace, error) {
 	var out Namespace
-	err := c.request("PATCH", "/apps/"+appID+"/namespaces/"+id, &in, &out)
+	err := c.request(ctx, "UpdateNamespace", "PATCH", "/apps/"+appID+"/namespaces/"+namespaceID, namespace, &out)
 	return out, err
 }
 
 // DeleteNamespace deletes the namespace with the specified ID, for the specified application ID.
-func (c *Client) DeleteNamespace(appID, namespaceID string) error {
-	err := c.request("DELETE", "/apps/"+appID+"/namespaces/"+namespaceID, nil, nil)
+func (c *Client) DeleteNamespace(ctx context.Context, appID, namespaceID string) error {
+	err := c.request(ctx, "DeleteNamespace", "DELETE", "/apps/"+appID+"/namespaces/"+namespaceID, nil, nil)
 	return err
 }
 
 func Interval(interval int) *int {
 	return &interval
 }
+
+// Bool returns a pointer to b, for setting the fields of update types such
+// as AppUpdate.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// String returns a pointer to s, for setting the fields of update types
+// such as AppUpdate.
+func String(s string) *string {
+	return &s
+}
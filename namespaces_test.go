@@ -1,6 +1,7 @@
 package control
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -9,6 +10,7 @@ import (
 )
 
 func TestNamespaces(t *testing.T) {
+	ctx := context.Background()
 	client, _ := newTestClient(t)
 	app := newTestApp(t, &client)
 
@@ -25,14 +27,25 @@ func TestNamespaces(t *testing.T) {
 		BatchingEnabled:  false,
 	}
 
-	n, err := client.CreateNamespace(app.ID, &namespace)
+	n, err := client.CreateNamespace(ctx, app.ID, &namespace)
 	assert.NoError(t, err)
 	assert.Equal(t, namespace, n)
 
-	namespaces, err := client.Namespaces(app.ID)
+	namespaces, err := client.Namespaces(ctx, app.ID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, namespaces)
 
+	n, err = client.UpdateNamespace(ctx, app.ID, namespace.ID, &NamespaceUpdate{
+		Authenticated:    Bool(true),
+		Persisted:        Bool(true),
+		PersistLast:      Bool(true),
+		PushEnabled:      Bool(true),
+		TlsOnly:          Bool(true),
+		ExposeTimeserial: Bool(true),
+		BatchingEnabled:  Bool(true),
+		BatchingInterval: Interval(100),
+	})
+	assert.NoError(t, err)
 	namespace = Namespace{
 		ID:               namespace.ID,
 		Authenticated:    true,
@@ -44,46 +57,50 @@ func TestNamespaces(t *testing.T) {
 		BatchingEnabled:  true,
 		BatchingInterval: Interval(100),
 	}
+	assert.Equal(t, namespace, n)
 
-	n, err = client.UpdateNamespace(app.ID, &namespace)
+	n, err = client.UpdateNamespace(ctx, app.ID, namespace.ID, &NamespaceUpdate{
+		BatchingEnabled: Bool(false),
+	})
 	assert.NoError(t, err)
+	namespace.BatchingEnabled = false
+	namespace.BatchingInterval = nil
 	assert.Equal(t, namespace, n)
 
-	namespace = Namespace{
-		ID:               namespace.ID,
-		Authenticated:    true,
-		Persisted:        true,
-		PersistLast:      true,
-		PushEnabled:      true,
-		TlsOnly:          true,
-		ExposeTimeserial: true,
-		BatchingEnabled:  false,
-	}
-
-	n, err = client.UpdateNamespace(app.ID, &namespace)
+	n, err = client.UpdateNamespace(ctx, app.ID, namespace.ID, &NamespaceUpdate{
+		ConflationEnabled:  Bool(true),
+		ConflationInterval: Interval(1000),
+		ConflationKey:      String("test"),
+	})
 	assert.NoError(t, err)
+	namespace.ConflationEnabled = true
+	namespace.ConflationInterval = Interval(1000)
+	namespace.ConflationKey = "test"
 	assert.Equal(t, namespace, n)
 
-	namespace = Namespace{
-		ID:                 namespace.ID,
-		Authenticated:      true,
-		Persisted:          true,
-		PersistLast:        true,
-		PushEnabled:        true,
-		TlsOnly:            true,
-		ExposeTimeserial:   true,
-		ConflationEnabled:  true,
-		ConflationInterval: Interval(1000),
-		ConflationKey:      "test",
-	}
+	err = client.DeleteNamespace(ctx, app.ID, namespace.ID)
+	assert.NoError(t, err)
 
-	n, err = client.UpdateNamespace(app.ID, &namespace)
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
-	assert.Equal(t, namespace, n)
+}
 
-	err = client.DeleteNamespace(app.ID, namespace.ID)
+func TestNamespaceLookup(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	namespace := Namespace{ID: "test-namespace-" + fmt.Sprint(rand.Uint64())}
+	n, err := client.CreateNamespace(ctx, app.ID, &namespace)
 	assert.NoError(t, err)
 
-	err = client.DeleteApp(app.ID)
+	n2, err := client.Namespace(ctx, app.ID, namespace.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, n, n2)
+
+	_, err = client.Namespace(ctx, app.ID, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
 }
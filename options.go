@@ -0,0 +1,195 @@
+package control
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"time"
+)
+
+// Option configures a Client created by NewClient or NewClientWithURL.
+type Option func(*options)
+
+type options struct {
+	url        string
+	httpClient *http.Client
+	transport  http.RoundTripper
+	timeout    time.Duration
+	proxy      func(*http.Request) (*neturl.URL, error)
+	tlsConfig  *tls.Config
+	header     http.Header
+	agents     []string
+	retry      RetryPolicy
+	limiter    *rateLimiter
+
+	tokenSource TokenSource
+	middleware  []Middleware
+	logger      *slog.Logger
+	logLevels   *LogLevels
+	metrics     Metrics
+	dryRun      *DryRun
+
+	// err is the first invalid option, which newClient returns.
+	err error
+}
+
+// setErr records err, unless an earlier option was already invalid.
+func (o *options) setErr(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// WithURL sets the base url of the REST API. It defaults to API_URL.
+func WithURL(url string) Option {
+	return func(o *options) {
+		o.url = url
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to make requests. It defaults to
+// http.DefaultClient.
+//
+// The client is copied, so later changes to it do not affect the Client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithTransport sets the RoundTripper used to make requests, replacing the
+// transport of the HTTP client.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithTimeout sets a timeout for each call made by the Client, including any
+// retries. A deadline already set on the context passed to a method still
+// applies.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithProxy sets the function used to choose a proxy for each request, see
+// http.Transport.Proxy. Use http.ProxyURL to always use the same proxy.
+//
+// It can only be used if the transport is an *http.Transport.
+func WithProxy(proxy func(*http.Request) (*neturl.URL, error)) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the REST API.
+//
+// It can only be used if the transport is an *http.Transport.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithHeader adds an HTTP header which is sent with every request. It can
+// not be used to replace the Authorization or Ably-Agent headers.
+func WithHeader(key, value string) Option {
+	return func(o *options) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// WithAblyAgent appends an extra entry to the value sent as the Ably-Agent
+// HTTP header, like AppendAblyAgent.
+func WithAblyAgent(product, version string) Option {
+	return func(o *options) {
+		o.agents = append(o.agents, fmt.Sprintf("%s/%s", product, version))
+	}
+}
+
+// newClient creates a Client from the given options without making any
+// requests.
+func newClient(token string, opts []Option) (Client, error) {
+	o := options{url: API_URL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.err != nil {
+		return Client{}, o.err
+	}
+
+	httpClient, err := o.buildHTTPClient()
+	if err != nil {
+		return Client{}, err
+	}
+
+	if o.tokenSource == nil {
+		o.tokenSource = StaticTokenSource(token)
+	}
+
+	client := Client{
+		tokenSource: o.tokenSource,
+		account:     &account{},
+		Url:         o.url,
+		ablyAgent:   defaultAblyAgent,
+		httpClient:  httpClient,
+		header:      o.header,
+		timeout:     o.timeout,
+		retry:       o.retry,
+		limiter:     o.limiter,
+		middleware:  o.middleware,
+		logger:      o.logger,
+		metrics:     o.metrics,
+		logLevels:   DefaultLogLevels,
+	}
+	if o.dryRun != nil {
+		client.middleware = append(slices.Clone(o.middleware), o.dryRun.middleware)
+	}
+	if o.logLevels != nil {
+		client.logLevels = *o.logLevels
+	}
+	for _, agent := range o.agents {
+		client.ablyAgent += " " + agent
+	}
+	return client, nil
+}
+
+func (o *options) buildHTTPClient() (*http.Client, error) {
+	client := http.Client{}
+	if o.httpClient != nil {
+		client = *o.httpClient
+	}
+	if o.transport != nil {
+		client.Transport = o.transport
+	}
+	if o.proxy == nil && o.tlsConfig == nil {
+		return &client, nil
+	}
+
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, errors.New("control: WithProxy and WithTLSConfig require an *http.Transport")
+	}
+	transport = transport.Clone()
+	if o.proxy != nil {
+		transport.Proxy = o.proxy
+	}
+	if o.tlsConfig != nil {
+		transport.TLSClientConfig = o.tlsConfig.Clone()
+	}
+	client.Transport = transport
+	return &client, nil
+}
@@ -0,0 +1,88 @@
+package control
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripperFunc allows a function to be used as an http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestOptions tests that client options are applied to requests.
+func TestOptions(t *testing.T) {
+	ctx := context.Background()
+	var req *http.Request
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"account":{"id":"acc"}}`))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var transportUsed bool
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		transportUsed = true
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	client, me, err := NewClient(ctx, "s3cr3t",
+		WithURL(srv.URL),
+		WithTransport(transport),
+		WithHeader("X-Extra", "a"),
+		WithHeader("Authorization", "ignored"),
+		WithAblyAgent("test", "1.2.3"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "acc", me.Account.ID)
+	assert.Equal(t, srv.URL, client.Url)
+	assert.True(t, transportUsed)
+	assert.Equal(t, "a", req.Header.Get("X-Extra"))
+	assert.Equal(t, "Bearer s3cr3t", req.Header.Get("Authorization"))
+	assert.Equal(t, "ably-control-go/"+VERSION+" test/1.2.3", req.Header.Get("Ably-Agent"))
+}
+
+// TestWithTimeout tests that WithTimeout limits the duration of requests.
+func TestWithTimeout(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	_, _, err := NewClientWithURL(context.Background(), "s3cr3t", srv.URL, WithTimeout(50*time.Millisecond))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestWithTLSConfig tests that proxy and TLS options are applied to the
+// transport, and rejected for transports they can not be applied to.
+func TestWithTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	_, _, err := NewClientWithURL(ctx, "s3cr3t", srv.URL)
+	assert.Error(t, err)
+
+	config := &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	_, _, err = NewClientWithURL(ctx, "s3cr3t", srv.URL, WithTLSConfig(config))
+	assert.NoError(t, err)
+
+	_, _, err = NewClientWithURL(ctx, "s3cr3t", srv.URL,
+		WithTransport(roundTripperFunc(http.DefaultTransport.RoundTrip)),
+		WithTLSConfig(config),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,639 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/ably/ably-control-go/internal/redact"
+)
+
+// Action is the kind of change a Change makes.
+type Action string
+
+const (
+	// ActionCreate creates an item.
+	ActionCreate Action = "create"
+	// ActionUpdate updates an item.
+	ActionUpdate Action = "update"
+	// ActionDelete deletes an item. Keys can't be deleted, so they are
+	// revoked.
+	ActionDelete Action = "delete"
+)
+
+// Change is a single change in a Plan.
+type Change struct {
+	// What the change does.
+	Action Action
+	// The kind of item, "app", "namespace", "key", "queue", "rule" or
+	// "ingress rule".
+	Resource string
+	// The ID of the live item which is updated or deleted.
+	ID string
+	// The item in the config: the app name, a namespace ID, a key or queue
+	// ref, or a position such as "rules[0]". It is empty for items which
+	// are deleted because they are not in the config.
+	Ref string
+	// The request body, which is one of *AppUpdate, *Namespace,
+	// *NamespaceUpdate, *NewKey, *KeyUpdate, *NewQueue, *NewRule,
+	// *RuleUpdate or *NewIngressRule, or nil for deletes. Rule targets hold
+	// refs, which are replaced by IDs when the plan is applied.
+	Body interface{}
+}
+
+// String returns the change as a line such as "create key webhooks" or
+// "delete rule abc123".
+func (c Change) String() string {
+	name := c.Ref
+	if name == "" {
+		name = c.ID
+	} else if c.ID != "" && c.ID != c.Ref {
+		name += " (" + c.ID + ")"
+	}
+	return string(c.Action) + " " + c.Resource + " " + name
+}
+
+// Plan is the list of changes which make an app match an AppConfig, made by
+// PlanApp.
+type Plan struct {
+	// The ID of the app.
+	AppID string
+	// The changes, in the order ApplyPlan makes them.
+	Changes []Change
+
+	// keys and queues map the refs of keys and queues which already exist
+	// to their IDs.
+	keys   map[string]string
+	queues map[string]string
+	// deadLetter holds the dead letter queues in the config which don't
+	// exist yet, by ref.
+	deadLetter map[string]QueueConfig
+}
+
+// Empty reports whether the plan has no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// PlanApp compares the app with the specified ID to cfg, and returns the
+// changes which would make the app match it. Nothing is changed until the
+// plan is passed to ApplyPlan.
+//
+// Namespaces are matched by ID, keys by name, and queues by name and region.
+// Rules and ingress rules have no names, so a rule which is the same as one
+// in cfg is left alone, a rule with the same type and source as one in cfg
+// is updated, and the rest are created or deleted. Queues can't be updated,
+// so a queue whose settings differ is replaced, and so are the rules which
+// refer to it. Dead letter queues are never created or deleted.
+//
+// The changes are ordered so that keys and queues exist before the rules
+// which refer to them, and rules are deleted before the queues and keys
+// they refer to.
+//
+// The APNs certificate and private key in cfg are ignored, since the Control
+// API does not return them, as are push credentials redacted by ExportApp. An
+// error is returned if rule targets contain secrets redacted by ExportApp.
+func (c *Client) PlanApp(ctx context.Context, appID string, cfg *AppConfig) (*Plan, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	for i, rule := range cfg.Rules {
+		if hasRedacted(rule.Target) {
+			return nil, fmt.Errorf("control: app config: rules[%d] contains redacted secrets", i)
+		}
+	}
+	for i, rule := range cfg.IngressRules {
+		if hasRedacted(rule.Target) {
+			return nil, fmt.Errorf("control: app config: ingressRules[%d] contains redacted secrets", i)
+		}
+	}
+
+	app, err := c.App(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := c.Namespaces(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := c.Keys(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	queues, err := c.Queues(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	rules, ingressRules, err := c.appRules(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		AppID:      appID,
+		keys:       map[string]string{},
+		queues:     map[string]string{},
+		deadLetter: map[string]QueueConfig{},
+	}
+	if update, ok := appUpdate(app, cfg.App); ok {
+		plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Resource: "app", ID: appID, Ref: cfg.App.Name, Body: update})
+	}
+	plan.Changes = append(plan.Changes, planNamespaces(namespaces, cfg.Namespaces)...)
+	keyChanges, keyDeletes := plan.planKeys(keys, cfg.Keys)
+	queueDeletes, queueCreates := plan.planQueues(queues, cfg.Queues)
+	replaced := map[string]bool{}
+	for _, change := range queueDeletes {
+		replaced[change.ID] = true
+	}
+	ruleChanges, ruleDeletes := plan.planRules(rules, cfg.Rules, replaced)
+	ingressChanges, ingressDeletes := planIngressRules(ingressRules, cfg.IngressRules)
+
+	plan.Changes = append(plan.Changes, keyChanges...)
+	plan.Changes = append(plan.Changes, ruleDeletes...)
+	plan.Changes = append(plan.Changes, ingressDeletes...)
+	plan.Changes = append(plan.Changes, queueDeletes...)
+	plan.Changes = append(plan.Changes, queueCreates...)
+	plan.Changes = append(plan.Changes, ruleChanges...)
+	plan.Changes = append(plan.Changes, ingressChanges...)
+	plan.Changes = append(plan.Changes, keyDeletes...)
+	return plan, nil
+}
+
+// appUpdate returns the update which changes the settings of live to
+// desired, and whether there is anything to change.
+func appUpdate(live App, desired AppSettings) (*AppUpdate, bool) {
+	var update AppUpdate
+	changed := false
+	setString := func(field **string, live, desired string) {
+		if live != desired && desired != redact.Placeholder {
+			*field = String(desired)
+			changed = true
+		}
+	}
+	setBool := func(field **bool, live, desired bool) {
+		if live != desired {
+			*field = Bool(desired)
+			changed = true
+		}
+	}
+	setString(&update.Name, live.Name, desired.Name)
+	if desired.Status != "" {
+		setString(&update.Status, live.Status, desired.Status)
+	}
+	setBool(&update.TLSOnly, live.TLSOnly, desired.TLSOnly)
+	setString(&update.FcmKey, live.FcmKey, desired.FcmKey)
+	setString(&update.FcmServiceAccount, live.FcmServiceAccount, desired.FcmServiceAccount)
+	setString(&update.FcmProjectId, live.FcmProjectId, desired.FcmProjectId)
+	setBool(&update.ApnsUseSandboxEndpoint, live.ApnsUseSandboxEndpoint, desired.ApnsUseSandboxEndpoint)
+	return &update, changed
+}
+
+// planNamespaces returns the changes which make the live namespaces match
+// the desired ones.
+func planNamespaces(live, desired []Namespace) []Change {
+	var changes []Change
+	byID := map[string]Namespace{}
+	for _, namespace := range live {
+		byID[namespace.ID] = namespace
+	}
+	for _, namespace := range desired {
+		current, ok := byID[namespace.ID]
+		if !ok {
+			changes = append(changes, Change{Action: ActionCreate, Resource: "namespace", Ref: namespace.ID, Body: &namespace})
+			continue
+		}
+		delete(byID, namespace.ID)
+		if update, ok := namespaceUpdate(current, namespace); ok {
+			changes = append(changes, Change{Action: ActionUpdate, Resource: "namespace", ID: namespace.ID, Ref: namespace.ID, Body: update})
+		}
+	}
+	for _, namespace := range live {
+		if _, ok := byID[namespace.ID]; ok {
+			changes = append(changes, Change{Action: ActionDelete, Resource: "namespace", ID: namespace.ID})
+		}
+	}
+	return changes
+}
+
+// namespaceUpdate returns the update which changes the settings of live to
+// desired, and whether there is anything to change. Intervals which are not
+// set in desired are left as they are.
+func namespaceUpdate(live, desired Namespace) (*NamespaceUpdate, bool) {
+	var update NamespaceUpdate
+	changed := false
+	setBool := func(field **bool, live, desired bool) {
+		if live != desired {
+			*field = Bool(desired)
+			changed = true
+		}
+	}
+	setInterval := func(field **int, live, desired *int) {
+		if desired != nil && (live == nil || *live != *desired) {
+			*field = Interval(*desired)
+			changed = true
+		}
+	}
+	setBool(&update.Authenticated, live.Authenticated, desired.Authenticated)
+	setBool(&update.Persisted, live.Persisted, desired.Persisted)
+	setBool(&update.PersistLast, live.PersistLast, desired.PersistLast)
+	setBool(&update.PushEnabled, live.PushEnabled, desired.PushEnabled)
+	setBool(&update.TlsOnly, live.TlsOnly, desired.TlsOnly)
+	setBool(&update.ExposeTimeserial, live.ExposeTimeserial, desired.ExposeTimeserial)
+	setBool(&update.BatchingEnabled, live.BatchingEnabled, desired.BatchingEnabled)
+	setInterval(&update.BatchingInterval, live.BatchingInterval, desired.BatchingInterval)
+	setBool(&update.ConflationEnabled, live.ConflationEnabled, desired.ConflationEnabled)
+	setInterval(&update.ConflationInterval, live.ConflationInterval, desired.ConflationInterval)
+	if live.ConflationKey != desired.ConflationKey {
+		update.ConflationKey = String(desired.ConflationKey)
+		changed = true
+	}
+	return &update, changed
+}
+
+// planKeys returns the changes which make the live keys match the desired
+// ones, and separately the revocations of keys which are not desired.
+// Revoked keys are ignored.
+func (p *Plan) planKeys(live []Key, desired []KeyConfig) ([]Change, []Change) {
+	var changes, deletes []Change
+	used := make([]bool, len(live))
+	for _, key := range desired {
+		i := firstUnused(live, used, func(k Key) bool {
+			return k.Status == 0 && k.Name == key.Name
+		})
+		if i < 0 {
+			changes = append(changes, Change{Action: ActionCreate, Resource: "key", Ref: key.Ref, Body: &NewKey{
+				Name:            key.Name,
+				Capability:      key.Capability,
+				RevocableTokens: key.RevocableTokens,
+			}})
+			continue
+		}
+		used[i] = true
+		p.keys[key.Ref] = live[i].ID
+		var update KeyUpdate
+		if !sameCapability(live[i].Capability, key.Capability) {
+			update.Capability = key.Capability
+		}
+		if live[i].RevocableTokens != key.RevocableTokens {
+			update.RevocableTokens = Bool(key.RevocableTokens)
+		}
+		if update.Capability != nil || update.RevocableTokens != nil {
+			changes = append(changes, Change{Action: ActionUpdate, Resource: "key", ID: live[i].ID, Ref: key.Ref, Body: &update})
+		}
+	}
+	for i, key := range live {
+		if !used[i] && key.Status == 0 {
+			deletes = append(deletes, Change{Action: ActionDelete, Resource: "key", ID: key.ID})
+		}
+	}
+	return changes, deletes
+}
+
+// sameCapability reports whether two capabilities allow the same
+// operations, regardless of the order they are listed in.
+func sameCapability(a, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for resource, ops := range a {
+		other, ok := b[resource]
+		if !ok {
+			return false
+		}
+		ops, other = slices.Clone(ops), slices.Clone(other)
+		slices.Sort(ops)
+		slices.Sort(other)
+		if !slices.Equal(ops, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// planQueues returns the deletions and creations which make the live queues
+// match the desired ones. A queue whose settings differ is deleted and
+// created again.
+func (p *Plan) planQueues(live []Queue, desired []QueueConfig) ([]Change, []Change) {
+	var deletes, creates []Change
+	used := make([]bool, len(live))
+	for _, queue := range desired {
+		if queue.DeadLetter {
+			if q, ok := findDeadLetter(live, queue.Name, queue.Region); ok {
+				p.queues[queue.Ref] = q.ID
+			} else {
+				p.deadLetter[queue.Ref] = queue
+			}
+			continue
+		}
+		i := firstUnused(live, used, func(q Queue) bool {
+			return !q.DeadLetter && q.Name == queue.Name && q.Region == queue.Region
+		})
+		if i >= 0 {
+			used[i] = true
+			current := live[i]
+			if (queue.Ttl == 0 || queue.Ttl == current.Ttl) && (queue.MaxLength == 0 || queue.MaxLength == current.MaxLength) {
+				p.queues[queue.Ref] = current.ID
+				continue
+			}
+			deletes = append(deletes, Change{Action: ActionDelete, Resource: "queue", ID: current.ID, Ref: queue.Ref})
+		}
+		creates = append(creates, Change{Action: ActionCreate, Resource: "queue", Ref: queue.Ref, Body: &NewQueue{
+			Name:      queue.Name,
+			Ttl:       queue.Ttl,
+			MaxLength: queue.MaxLength,
+			Region:    queue.Region,
+		}})
+	}
+	for i, queue := range live {
+		if !used[i] && !queue.DeadLetter {
+			deletes = append(deletes, Change{Action: ActionDelete, Resource: "queue", ID: queue.ID})
+		}
+	}
+	return deletes, creates
+}
+
+// planRules returns the creations and updates which make the live rules
+// match the desired ones, and separately the deletions of rules which are
+// not desired. Rules which refer to a queue in deletedQueues are deleted and
+// created again, since the queue is deleted before the rules are updated.
+func (p *Plan) planRules(live []Rule, desired []NewRule, deletedQueues map[string]bool) ([]Change, []Change) {
+	var changes, deletes []Change
+	used := make([]bool, len(live))
+	matched := make([]bool, len(desired))
+	for i, rule := range desired {
+		// a rule which refers to a key or queue which does not exist yet
+		// can't be the same as a live rule.
+		target, err := remapTarget(rule.Target, p.queues, p.keys)
+		if err != nil {
+			continue
+		}
+		rule.Target = target
+		if j := firstUnused(live, used, func(r Rule) bool { return sameRule(r, rule) }); j >= 0 {
+			used[j], matched[i] = true, true
+		}
+	}
+	for i, rule := range desired {
+		if matched[i] {
+			continue
+		}
+		ref := fmt.Sprintf("rules[%d]", i)
+		j := firstUnused(live, used, func(r Rule) bool {
+			return r.Target.TargetType() == rule.Target.TargetType() && r.Source == rule.Source
+		})
+		if j < 0 {
+			changes = append(changes, Change{Action: ActionCreate, Resource: "rule", Ref: ref, Body: &rule})
+			continue
+		}
+		used[j] = true
+		if resource, id := targetReference(live[j].Target); resource == "queue" && deletedQueues[id] {
+			deletes = append(deletes, Change{Action: ActionDelete, Resource: "rule", ID: live[j].ID})
+			changes = append(changes, Change{Action: ActionCreate, Resource: "rule", Ref: ref, Body: &rule})
+			continue
+		}
+		update := RuleUpdate{Source: &rule.Source, Target: rule.Target}
+		if rule.Status != "" {
+			update.Status = String(rule.Status)
+		}
+		if rule.RequestMode != "" {
+			update.RequestMode = &rule.RequestMode
+		}
+		changes = append(changes, Change{Action: ActionUpdate, Resource: "rule", ID: live[j].ID, Ref: ref, Body: &update})
+	}
+	for i, rule := range live {
+		if !used[i] {
+			deletes = append(deletes, Change{Action: ActionDelete, Resource: "rule", ID: rule.ID})
+		}
+	}
+	return changes, deletes
+}
+
+// sameRule reports whether live is the same as desired, whose target refers
+// to keys and queues by ID. The status and request mode are only compared
+// if they are set in desired.
+func sameRule(live Rule, desired NewRule) bool {
+	if desired.Status == "" {
+		desired.Status = live.Status
+	}
+	if desired.RequestMode == "" {
+		desired.RequestMode = live.RequestMode
+	}
+	return sameJSON(&NewRule{Status: live.Status, RequestMode: live.RequestMode, Source: live.Source, Target: live.Target}, &desired)
+}
+
+// planIngressRules returns the creations and updates which make the live
+// ingress rules match the desired ones, and separately the deletions of
+// ingress rules which are not desired.
+func planIngressRules(live []IngressRule, desired []NewIngressRule) ([]Change, []Change) {
+	var changes, deletes []Change
+	used := make([]bool, len(live))
+	matched := make([]bool, len(desired))
+	for i, rule := range desired {
+		if j := firstUnused(live, used, func(r IngressRule) bool { return sameIngressRule(r, rule) }); j >= 0 {
+			used[j], matched[i] = true, true
+		}
+	}
+	for i, rule := range desired {
+		if matched[i] {
+			continue
+		}
+		ref := fmt.Sprintf("ingressRules[%d]", i)
+		j := firstUnused(live, used, func(r IngressRule) bool {
+			return r.Target.TargetType() == rule.Target.TargetType()
+		})
+		if j < 0 {
+			changes = append(changes, Change{Action: ActionCreate, Resource: "ingress rule", Ref: ref, Body: &rule})
+			continue
+		}
+		used[j] = true
+		changes = append(changes, Change{Action: ActionUpdate, Resource: "ingress rule", ID: live[j].ID, Ref: ref, Body: &rule})
+	}
+	for i, rule := range live {
+		if !used[i] {
+			deletes = append(deletes, Change{Action: ActionDelete, Resource: "ingress rule", ID: rule.ID})
+		}
+	}
+	return changes, deletes
+}
+
+// sameIngressRule reports whether live is the same as desired. The status is
+// only compared if it is set in desired.
+func sameIngressRule(live IngressRule, desired NewIngressRule) bool {
+	if desired.Status == "" {
+		desired.Status = live.Status
+	}
+	return sameJSON(&NewIngressRule{Status: live.Status, Target: live.Target}, &desired)
+}
+
+// firstUnused returns the index of the first item which is not used and
+// matches match, or -1 if there is none.
+func firstUnused[T any](items []T, used []bool, match func(T) bool) int {
+	for i, item := range items {
+		if !used[i] && match(item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameJSON reports whether a and b have the same JSON encoding.
+func sameJSON(a, b interface{}) bool {
+	aData, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bData, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aData, bData)
+}
+
+// ApplyError is returned by ApplyPlan when a change fails.
+type ApplyError struct {
+	// The change which failed.
+	Change Change
+	// The number of changes which were made before it.
+	Applied int
+	// The error returned when making the change.
+	Err error
+}
+
+// ApplyError implements the Error interface.
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("control: %s: %v", e.Change, e.Err)
+}
+
+// Unwrap returns the error returned when making the change.
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyPlan makes the changes in plan, in order. Refs in rule targets are
+// replaced by the IDs of the keys and queues they refer to, including those
+// created earlier in the plan.
+//
+// ApplyPlan stops at the first change which fails, and returns an
+// *ApplyError describing it. The changes before it have been made, so
+// planning again shows what is left to do.
+//
+// If ctx carries an idempotency key, see ContextWithIdempotencyKey, each
+// create call uses a key derived from it.
+func (c *Client) ApplyPlan(ctx context.Context, plan *Plan) error {
+	a := applier{
+		client:     c,
+		appID:      plan.AppID,
+		keys:       map[string]string{},
+		queues:     map[string]string{},
+		deadLetter: map[string]QueueConfig{},
+	}
+	maps.Copy(a.keys, plan.keys)
+	maps.Copy(a.queues, plan.queues)
+	maps.Copy(a.deadLetter, plan.deadLetter)
+	for i, change := range plan.Changes {
+		if err := a.apply(ctx, change); err != nil {
+			return &ApplyError{Change: change, Applied: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// applier makes the changes in a plan, keeping track of the IDs of the keys
+// and queues created by it.
+type applier struct {
+	client     *Client
+	appID      string
+	keys       map[string]string
+	queues     map[string]string
+	deadLetter map[string]QueueConfig
+}
+
+// apply makes a single change.
+func (a *applier) apply(ctx context.Context, change Change) error {
+	c := a.client
+	if change.Action == ActionDelete {
+		switch change.Resource {
+		case "namespace":
+			return c.DeleteNamespace(ctx, a.appID, change.ID)
+		case "key":
+			return c.RevokeKey(ctx, a.appID, change.ID)
+		case "queue":
+			return c.DeleteQueue(ctx, a.appID, change.ID)
+		case "rule":
+			return c.DeleteRule(ctx, a.appID, change.ID)
+		case "ingress rule":
+			return c.DeleteIngressRule(ctx, a.appID, change.ID)
+		}
+		return fmt.Errorf("control: can't delete %s %s", change.Resource, change.ID)
+	}
+
+	if change.Action == ActionCreate {
+		ctx = deriveIdempotencyKey(ctx, change.Resource+"/"+change.Ref)
+	}
+	var err error
+	switch body := change.Body.(type) {
+	case *AppUpdate:
+		_, err = c.UpdateApp(ctx, a.appID, body)
+	case *Namespace:
+		_, err = c.CreateNamespace(ctx, a.appID, body)
+	case *NamespaceUpdate:
+		_, err = c.UpdateNamespace(ctx, a.appID, change.ID, body)
+	case *NewKey:
+		var key Key
+		key, err = c.CreateKey(ctx, a.appID, body)
+		a.keys[change.Ref] = key.ID
+	case *KeyUpdate:
+		_, err = c.UpdateKey(ctx, a.appID, change.ID, body)
+	case *NewQueue:
+		var queue Queue
+		queue, err = c.CreateQueue(ctx, a.appID, body)
+		a.queues[change.Ref] = queue.ID
+	case *NewRule:
+		rule := *body
+		if rule.Target, err = a.resolve(ctx, body.Target); err != nil {
+			return err
+		}
+		_, err = c.CreateRule(ctx, a.appID, &rule)
+	case *RuleUpdate:
+		update := *body
+		if update.Target, err = a.resolve(ctx, body.Target); err != nil {
+			return err
+		}
+		_, err = c.UpdateRule(ctx, a.appID, change.ID, &update)
+	case *NewIngressRule:
+		if change.Action == ActionCreate {
+			_, err = c.CreateIngressRule(ctx, a.appID, body)
+		} else {
+			_, err = c.UpdateIngressRule(ctx, a.appID, change.ID, body)
+		}
+	default:
+		err = fmt.Errorf("control: unsupported change body %T", change.Body)
+	}
+	return err
+}
+
+// resolve returns a copy of target which refers to keys and queues by ID
+// rather than by ref. Dead letter queues which did not exist when the plan
+// was made are looked up the first time they are needed.
+func (a *applier) resolve(ctx context.Context, target Target) (Target, error) {
+	if target == nil {
+		return nil, nil
+	}
+	if resource, ref := targetReference(target); resource == "queue" {
+		if queue, ok := a.deadLetter[ref]; ok {
+			queues, err := a.client.Queues(ctx, a.appID)
+			if err != nil {
+				return nil, err
+			}
+			q, ok := findDeadLetter(queues, queue.Name, queue.Region)
+			if !ok {
+				return nil, fmt.Errorf("control: dead letter queue %s was not created", queue.Name)
+			}
+			a.queues[ref] = q.ID
+			delete(a.deadLetter, ref)
+		}
+	}
+	return remapTarget(target, a.queues, a.keys)
+}
@@ -0,0 +1,260 @@
+package control
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanApp(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	_, err := client.CreateNamespace(ctx, app.ID, &Namespace{ID: "old"})
+	assert.NoError(t, err)
+	unused, err := client.CreateKey(ctx, app.ID, &NewKey{Name: "unused", Capability: map[string][]string{"*": {"subscribe"}}})
+	assert.NoError(t, err)
+	queue, err := client.CreateQueue(ctx, app.ID, &NewQueue{Name: "events", Ttl: 60, MaxLength: 100, Region: UsEast1A})
+	assert.NoError(t, err)
+	webhook, err := client.CreateRule(ctx, app.ID, &NewRule{
+		Source: Source{Type: ChannelMessage},
+		Target: &HttpTarget{Url: "https://old.example.com"},
+	})
+	assert.NoError(t, err)
+
+	cfg := &AppConfig{
+		Version:    AppConfigVersion,
+		App:        AppSettings{Name: app.Name, TLSOnly: true},
+		Namespaces: []Namespace{{ID: "chat", Persisted: true}},
+		Keys:       []KeyConfig{{Ref: "webhooks", Name: "Webhooks", Capability: map[string][]string{"*": {"publish"}}}},
+		Queues:     []QueueConfig{{Ref: "events", Name: "events", Region: UsEast1A, Ttl: 30, MaxLength: 100}},
+		Rules: []NewRule{
+			{Source: Source{Type: ChannelPresence}, Target: &AmqpTarget{QueueID: "events"}},
+			{Source: Source{Type: ChannelMessage}, Target: &HttpTarget{Url: "https://example.com", SigningKeyID: "webhooks"}},
+		},
+	}
+	plan, err := client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	var changes []string
+	for _, change := range plan.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{
+		"update app " + app.Name + " (" + app.ID + ")",
+		"create namespace chat",
+		"delete namespace old",
+		"create key webhooks",
+		"delete queue events (" + queue.ID + ")",
+		"create queue events",
+		"create rule rules[0]",
+		"update rule rules[1] (" + webhook.ID + ")",
+		"delete key " + unused.ID,
+	}, changes)
+	assert.Equal(t, &AppUpdate{TLSOnly: Bool(true)}, plan.Changes[0].Body)
+
+	assert.NoError(t, client.ApplyPlan(ctx, plan))
+
+	key, err := client.FindKeyByName(ctx, app.ID, "Webhooks")
+	assert.NoError(t, err)
+	newQueue, err := client.FindQueueByName(ctx, app.ID, "events")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, newQueue.Ttl)
+	rules, err := client.Rules(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	for _, rule := range rules {
+		switch target := rule.Target.(type) {
+		case *AmqpTarget:
+			assert.Equal(t, newQueue.ID, target.QueueID)
+		case *HttpTarget:
+			assert.Equal(t, webhook.ID, rule.ID)
+			assert.Equal(t, key.ID, target.SigningKeyID)
+			assert.Equal(t, "https://example.com", target.Url)
+		}
+	}
+	unused, err = client.Key(ctx, app.ID, unused.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, unused.Status)
+
+	plan, err = client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "%v", plan.Changes)
+
+	// the exported config matches the app it was exported from.
+	exported, err := client.ExportApp(ctx, app.ID, ExportOptions{Secrets: true})
+	assert.NoError(t, err)
+	plan, err = client.PlanApp(ctx, app.ID, exported)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "%v", plan.Changes)
+
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestPlanAppReplacedQueue(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	queue, err := client.CreateQueue(ctx, app.ID, &NewQueue{Name: "events", Ttl: 60, MaxLength: 100, Region: UsEast1A})
+	assert.NoError(t, err)
+	rule, err := client.CreateRule(ctx, app.ID, &NewRule{Source: Source{Type: ChannelMessage}, Target: &AmqpTarget{QueueID: queue.ID}})
+	assert.NoError(t, err)
+
+	// the rule refers to the queue, so it is deleted before the queue and
+	// created again after it.
+	cfg := &AppConfig{
+		Version: AppConfigVersion,
+		App:     AppSettings{Name: app.Name},
+		Queues:  []QueueConfig{{Ref: "events", Name: "events", Region: UsEast1A, Ttl: 30, MaxLength: 100}},
+		Rules:   []NewRule{{Source: Source{Type: ChannelMessage}, Target: &AmqpTarget{QueueID: "events"}}},
+	}
+	plan, err := client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	var changes []string
+	for _, change := range plan.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{
+		"delete rule " + rule.ID,
+		"delete queue events (" + queue.ID + ")",
+		"create queue events",
+		"create rule rules[0]",
+	}, changes)
+
+	assert.NoError(t, client.ApplyPlan(ctx, plan))
+	newQueue, err := client.FindQueueByName(ctx, app.ID, "events")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, newQueue.Ttl)
+	rules, err := client.Rules(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, newQueue.ID, rules[0].Target.(*AmqpTarget).QueueID)
+
+	plan, err = client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "%v", plan.Changes)
+
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestPlanAppDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	// the dead letter queue is created along with the first queue in its
+	// region, so the rule which refers to it is only resolved when applied.
+	cfg := &AppConfig{
+		Version: AppConfigVersion,
+		App:     AppSettings{Name: app.Name},
+		Queues: []QueueConfig{
+			{Ref: "events", Name: "events", Region: UsEast1A, Ttl: 60, MaxLength: 100},
+			{Ref: "failed", Name: "deadletter", Region: UsEast1A, DeadLetter: true},
+		},
+		Rules: []NewRule{{Source: Source{Type: ChannelMessage}, Target: &AmqpTarget{QueueID: "failed"}}},
+	}
+	plan, err := client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	var changes []string
+	for _, change := range plan.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{"create queue events", "create rule rules[0]"}, changes)
+
+	assert.NoError(t, client.ApplyPlan(ctx, plan))
+	rules, err := client.Rules(ctx, app.ID)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, app.ID+":us-east-1-a:deadletter", rules[0].Target.(*AmqpTarget).QueueID)
+
+	plan, err = client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "%v", plan.Changes)
+
+	// dead letter queues which are not in the config are not deleted.
+	cfg.Queues = cfg.Queues[:1]
+	cfg.Rules = nil
+	plan, err = client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{{Action: ActionDelete, Resource: "rule", ID: rules[0].ID}}, plan.Changes)
+
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestPlanAppRedacted(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+
+	cfg := &AppConfig{
+		Version: AppConfigVersion,
+		App:     AppSettings{Name: "app"},
+		Rules: []NewRule{{Target: &KafkaTarget{
+			Authentication: KafkaAuthentication{Sasl: Sasl{Password: "[REDACTED]"}},
+		}}},
+	}
+	_, err := client.PlanApp(ctx, "app", cfg)
+	assert.EqualError(t, err, "control: app config: rules[0] contains redacted secrets")
+}
+
+func TestApplyPlanError(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	plan := &Plan{AppID: app.ID, Changes: []Change{
+		{Action: ActionCreate, Resource: "namespace", Ref: "chat", Body: &Namespace{ID: "chat"}},
+		{Action: ActionDelete, Resource: "queue", ID: "missing"},
+	}}
+	err := client.ApplyPlan(ctx, plan)
+	var applyErr *ApplyError
+	assert.ErrorAs(t, err, &applyErr)
+	assert.Equal(t, 1, applyErr.Applied)
+	assert.Equal(t, plan.Changes[1], applyErr.Change)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestApplyPlanIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	client, keys := newKeyRecordingClient(t)
+	app := newTestApp(t, &client)
+	t.Cleanup(func() { client.DeleteApp(ctx, app.ID) })
+
+	cfg := &AppConfig{
+		Version:    AppConfigVersion,
+		App:        AppSettings{Name: app.Name},
+		Namespaces: []Namespace{{ID: "chat"}},
+		Keys:       []KeyConfig{{Ref: "key", Name: "key", Capability: map[string][]string{"*": {"publish"}}}},
+		Queues:     []QueueConfig{{Ref: "events", Name: "events", Region: UsEast1A, Ttl: 60, MaxLength: 100}},
+		Rules:      []NewRule{{Source: Source{Type: ChannelMessage}, Target: &AmqpTarget{QueueID: "events"}}},
+	}
+	plan, err := client.PlanApp(ctx, app.ID, cfg)
+	assert.NoError(t, err)
+
+	// each create call made by ApplyPlan has its own key.
+	*keys = nil
+	assert.NoError(t, client.ApplyPlan(ContextWithIdempotencyKey(ctx, "deploy"), plan))
+	assert.Equal(t, []string{"deploy/namespace/chat", "deploy/key/key", "deploy/queue/events", "deploy/rule/rules[0]"}, *keys)
+}
+
+func TestSameCapability(t *testing.T) {
+	assert.True(t, sameCapability(
+		map[string][]string{"*": {"publish", "subscribe"}},
+		map[string][]string{"*": {"subscribe", "publish"}},
+	))
+	assert.False(t, sameCapability(
+		map[string][]string{"*": {"publish"}},
+		map[string][]string{"*": {"subscribe"}},
+	))
+	assert.False(t, sameCapability(
+		map[string][]string{"*": {"publish"}},
+		map[string][]string{"a": {"publish"}},
+	))
+}
@@ -1,5 +1,10 @@
 package control
 
+import (
+	"context"
+	"iter"
+)
+
 // Region is an enum of the possible queue regions.
 type Region string
 
@@ -90,21 +95,54 @@ type NewQueue struct {
 }
 
 // Queues lists the queues associated with the specified application ID.
-func (c *Client) Queues(appID string) ([]Queue, error) {
-	var queues []Queue
-	err := c.request("GET", "/apps/"+appID+"/queues", nil, &queues)
-	return queues, err
+func (c *Client) Queues(ctx context.Context, appID string) ([]Queue, error) {
+	return collect(c.AllQueues(ctx, appID))
+}
+
+// AllQueues returns an iterator over the queues associated with the
+// specified application ID, which fetches further pages as it is consumed.
+func (c *Client) AllQueues(ctx context.Context, appID string) iter.Seq2[Queue, error] {
+	return list[Queue](ctx, c, "Queues", "/apps/"+appID+"/queues")
+}
+
+// Queue fetches the queue with the specified queue ID. An error matching
+// ErrNotFound is returned if there is no such queue.
+func (c *Client) Queue(ctx context.Context, appID, queueID string) (Queue, error) {
+	return findByID(c.AllQueues(ctx, appID), "queue", "/apps/"+appID+"/queues", queueID, func(q Queue) string { return q.ID })
+}
+
+// FindQueueByName fetches the queue with the specified name. An error
+// matching ErrNotFound is returned if there is no such queue, or one
+// matching ErrAmbiguous if more than one queue has the name, in different
+// regions.
+func (c *Client) FindQueueByName(ctx context.Context, appID, name string) (Queue, error) {
+	return findByName(c.AllQueues(ctx, appID), "queue", "/apps/"+appID+"/queues", name,
+		func(q Queue) string { return q.Name },
+		func(q Queue) string { return q.ID },
+	)
 }
 
 // CreateQueue creates a queue for the application specified by application ID.
-func (c *Client) CreateQueue(appID string, queue *NewQueue) (Queue, error) {
+//
+// If the call is retried, a new queue with the same name and region is
+// assumed to have been created by an earlier attempt. When retries are
+// enabled the app's queues are listed before the first attempt, so that a
+// queue which already existed is not mistaken for it.
+func (c *Client) CreateQueue(ctx context.Context, appID string, queue *NewQueue) (Queue, error) {
 	var out Queue
-	err := c.request("POST", "/apps/"+appID+"/queues", &queue, &out)
+	queues := func(ctx context.Context) iter.Seq2[Queue, error] {
+		return c.AllQueues(ctx, appID)
+	}
+	find := findNew(ctx, c, queues,
+		func(q Queue) bool { return q.Name == queue.Name && q.Region == queue.Region },
+		func(q Queue) string { return q.ID },
+	)
+	err := c.create(ctx, "CreateQueue", "/apps/"+appID+"/queues", queue, &out, find)
 	return out, err
 }
 
 // DeleteQueue delete the queue with the specified queue name, from the application with the specified application ID.
-func (c *Client) DeleteQueue(appID, queueID string) error {
-	err := c.request("DELETE", "/apps/"+appID+"/queues/"+queueID, nil, nil)
+func (c *Client) DeleteQueue(ctx context.Context, appID, queueID string) error {
+	err := c.request(ctx, "DeleteQueue", "DELETE", "/apps/"+appID+"/queues/"+queueID, nil, nil)
 	return err
 }
@@ -1,6 +1,7 @@
 package control
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -9,6 +10,7 @@ import (
 )
 
 func TestQueues(t *testing.T) {
+	ctx := context.Background()
 	client, _ := newTestClient(t)
 	app := newTestApp(t, &client)
 
@@ -21,14 +23,14 @@ func TestQueues(t *testing.T) {
 		Region:    EuWest1A,
 	}
 
-	q, err := client.CreateQueue(app.ID, &queue)
+	q, err := client.CreateQueue(ctx, app.ID, &queue)
 	assert.NoError(t, err)
 	assert.Equal(t, queue.Name, q.Name)
 	assert.Equal(t, queue.Ttl, q.Ttl)
 	assert.Equal(t, queue.MaxLength, q.MaxLength)
 	assert.Equal(t, queue.Region, q.Region)
 
-	queues, err := client.Queues(app.ID)
+	queues, err := client.Queues(ctx, app.ID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, queues)
 
@@ -39,9 +41,44 @@ func TestQueues(t *testing.T) {
 		Region:    UsEast1A,
 	}
 
-	err = client.DeleteQueue(app.ID, q.ID)
+	err = client.DeleteQueue(ctx, app.ID, q.ID)
 	assert.NoError(t, err)
 
-	err = client.DeleteApp(app.ID)
+	err = client.DeleteApp(ctx, app.ID)
+	assert.NoError(t, err)
+}
+
+func TestQueueLookup(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+	app := newTestApp(t, &client)
+
+	queue := NewQueue{
+		Name:      "queue-key-" + fmt.Sprint(rand.Uint64()),
+		Ttl:       50,
+		MaxLength: 10,
+		Region:    EuWest1A,
+	}
+	q1, err := client.CreateQueue(ctx, app.ID, &queue)
+	assert.NoError(t, err)
+
+	q, err := client.Queue(ctx, app.ID, q1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, q1.ID, q.ID)
+	q, err = client.FindQueueByName(ctx, app.ID, queue.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, q1.ID, q.ID)
+
+	_, err = client.Queue(ctx, app.ID, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	queue.Region = UsEast1A
+	q2, err := client.CreateQueue(ctx, app.ID, &queue)
+	assert.NoError(t, err)
+	_, err = client.FindQueueByName(ctx, app.ID, queue.Name)
+	assert.ErrorIs(t, err, ErrAmbiguous)
+	assert.ErrorContains(t, err, q1.ID+", "+q2.ID)
+
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
 }
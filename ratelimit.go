@@ -0,0 +1,153 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the Client to rps requests per second on average,
+// allowing bursts of up to burst requests. Requests over the limit wait
+// until they are allowed, or until their context is done.
+//
+// The limit is shared by all goroutines using the Client and by copies of
+// it. When the Control API responds with a 429, or reports that no requests
+// remain in the current window, the limiter slows down and recovers
+// gradually as requests succeed again.
+//
+// rps and burst must be positive, otherwise creating the Client fails.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(o *options) {
+		if !(rps > 0) || burst < 1 {
+			o.setErr(fmt.Errorf("control: invalid rate limit of %v requests per second with a burst of %d", rps, burst))
+			return
+		}
+		o.limiter = newRateLimiter(rps, burst)
+	}
+}
+
+// rateLimiter is a token bucket which adapts its rate to rate limiting
+// signalled by the Control API.
+type rateLimiter struct {
+	mu sync.Mutex
+	// limit is the configured number of requests per second.
+	limit float64
+	// rate is the current number of requests per second, which is lowered
+	// below limit after the client was rate limited.
+	rate  float64
+	burst float64
+	// tokens is the number of requests which can be made immediately.
+	tokens float64
+	last   time.Time
+	// pausedUntil is set when the Control API asked for no requests to be
+	// made until then.
+	pausedUntil time.Time
+}
+
+// newRateLimiter returns a limiter for rps requests per second with bursts
+// of up to burst requests. Both must be positive.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		limit:  rps,
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens accumulated since the last call. It must be called
+// with mu held.
+func (l *rateLimiter) refill(now time.Time) {
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		l.tokens = min(l.tokens, l.burst)
+		l.last = now
+	}
+}
+
+// wait blocks until a request can be made.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+		var d time.Duration
+		if now.Before(l.pausedUntil) {
+			d = l.pausedUntil.Sub(now)
+		} else if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		} else {
+			d = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// update adapts the limiter to the rate limiting signalled by a response.
+func (l *rateLimiter) update(res *http.Response) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		// halve the rate, but don't let it drop so low that it takes
+		// too long to recover.
+		l.rate /= 2
+		l.rate = max(l.rate, l.limit/16)
+		l.tokens = 0
+		d, ok := retryAfter(res.Header, now)
+		if !ok {
+			d = time.Duration(float64(time.Second) / l.rate)
+		}
+		l.pause(now.Add(d))
+		return
+	}
+
+	// recover towards the configured rate.
+	if l.rate < l.limit {
+		l.rate = min(l.rate+l.limit/10, l.limit)
+	}
+
+	remaining, err := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	l.tokens = min(l.tokens, float64(remaining))
+	if remaining == 0 {
+		if reset, ok := rateLimitReset(res.Header, now); ok {
+			l.pause(reset)
+		}
+	}
+}
+
+// pause stops requests from being made until t. It must be called with mu
+// held.
+func (l *rateLimiter) pause(t time.Time) {
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// rateLimitReset parses the X-RateLimit-Reset header, which is either a unix
+// timestamp or a number of seconds from now.
+func rateLimitReset(header http.Header, now time.Time) (time.Time, bool) {
+	v, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || v < 0 {
+		return time.Time{}, false
+	}
+	// values this large can only be timestamps.
+	if v > 1e9 {
+		return time.Unix(v, 0), true
+	}
+	return now.Add(time.Duration(v) * time.Second), true
+}
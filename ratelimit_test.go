@@ -0,0 +1,103 @@
+package control
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("{}"))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client, err := newClient("s3cr3t", []Option{
+		WithURL(srv.URL),
+		WithRateLimit(20, 5),
+	})
+	assert.NoError(t, err)
+
+	// 5 requests are allowed immediately, the other 5 need to wait for
+	// 250ms in total.
+	ctx := context.Background()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Me(ctx)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(10), requests.Load())
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestRateLimitContext(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	assert.NoError(t, l.wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.wait(ctx), context.DeadlineExceeded)
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, test := range []struct {
+		rps   float64
+		burst int
+	}{
+		{0, 1},
+		{-1, 1},
+		{math.NaN(), 1},
+		{1, 0},
+	} {
+		_, err := newClient("s3cr3t", []Option{WithRateLimit(test.rps, test.burst)})
+		assert.ErrorContains(t, err, "control: invalid rate limit", "rps %v, burst %d", test.rps, test.burst)
+	}
+
+	_, _, err := NewClientWithURL(context.Background(), "s3cr3t", "http://localhost", WithRateLimit(0, 1))
+	assert.ErrorContains(t, err, "control: invalid rate limit")
+}
+
+func TestRateLimitAdapt(t *testing.T) {
+	l := newRateLimiter(10, 10)
+
+	l.update(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"2"}},
+	})
+	assert.Equal(t, 5.0, l.rate)
+	assert.Equal(t, 0.0, l.tokens)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), l.pausedUntil, 100*time.Millisecond)
+
+	// successful responses recover the rate.
+	for i := 0; i < 10; i++ {
+		l.update(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+	}
+	assert.Equal(t, 10.0, l.rate)
+
+	l.pausedUntil = time.Time{}
+	l.update(&http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {"3"},
+		},
+	})
+	assert.Equal(t, 0.0, l.tokens)
+	assert.WithinDuration(t, time.Now().Add(3*time.Second), l.pausedUntil, 100*time.Millisecond)
+}
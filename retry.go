@@ -0,0 +1,132 @@
+package control
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests which fail with a retryable error, such
+// as a 429 or 5xx response, are retried. See ErrorInfo.IsRetryable.
+//
+// Only GET, DELETE and PATCH requests are retried unless RetryPOST is set,
+// since retrying a POST may apply a change twice. Create calls for resources
+// which can be looked up, such as CreateApp, are the exception: before one
+// is retried the Client checks whether an earlier attempt created the
+// resource. They are also retried after a transport error, since the
+// response may have been lost after the change was applied. CreateRule and
+// CreateIngressRule can't check, so they are only retried if RetryPOST is
+// set.
+type RetryPolicy struct {
+	// The maximum number of attempts made for a request, including the
+	// first. Values below 2 disable retries.
+	MaxAttempts int
+	// The delay before the first retry. The delay doubles for each further
+	// retry and a random jitter of up to half the delay is subtracted.
+	MinBackoff time.Duration
+	// The maximum delay between attempts. If zero, the delay is not capped.
+	// This also caps the delay requested by a Retry-After header.
+	MaxBackoff time.Duration
+	// Allow POST requests to be retried.
+	RetryPOST bool
+}
+
+// DefaultRetryPolicy is a RetryPolicy suitable for most uses.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests. By default
+// requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// retryable reports whether a request which failed with the given error
+// should be retried.
+func (p *RetryPolicy) retryable(req *Request, errorInfo ErrorInfo) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodDelete, http.MethodPatch:
+	case http.MethodPost:
+		if !p.RetryPOST && !p.retryableCreate(req) {
+			return false
+		}
+	default:
+		return false
+	}
+	return errorInfo.IsRetryable()
+}
+
+// retryableCreate reports whether req is a create call which may be
+// retried: either it can check whether an earlier attempt created the
+// resource, or RetryPOST is set.
+func (p *RetryPolicy) retryableCreate(req *Request) bool {
+	return req.idempotent() && (req.find != nil || p.RetryPOST)
+}
+
+// delay returns how long to wait before the next attempt, given the number
+// of attempts made so far and the headers of the last response.
+func (p *RetryPolicy) delay(attempt int, header http.Header) time.Duration {
+	d, ok := retryAfter(header, time.Now())
+	if !ok {
+		d = p.MinBackoff
+		for i := 1; i < attempt && d < math.MaxInt64/2; i++ {
+			d *= 2
+		}
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			d = p.MaxBackoff
+		}
+		if d > 0 {
+			d -= rand.N(d/2 + 1)
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// sleep waits for d, returning early with an error if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
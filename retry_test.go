@@ -0,0 +1,122 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRetryTestServer starts a test HTTP server which responds to the first
+// failures requests with the given status code, and with an empty JSON
+// object after that. It returns the server and a pointer to the number of
+// requests it received.
+func newRetryTestServer(t *testing.T, failures, statusCode int) (*httptest.Server, *int) {
+	var requests int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if requests <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statusCode)
+			fmt.Fprintf(w, `{"message":"failed","code":%d,"statusCode":%d}`, statusCode*100, statusCode)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	srv, requests := newRetryTestServer(t, 2, http.StatusServiceUnavailable)
+	client, err := newClient("s3cr3t", []Option{
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	})
+	assert.NoError(t, err)
+
+	_, err = client.Me(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *requests)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	ctx := context.Background()
+	srv, requests := newRetryTestServer(t, 5, http.StatusServiceUnavailable)
+	client, err := newClient("s3cr3t", []Option{
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	})
+	assert.NoError(t, err)
+
+	err = client.DeleteApp(ctx, "app")
+	assert.Equal(t, 3, *requests)
+	errorInfo := err.(ErrorInfo)
+	assert.Equal(t, 50300, errorInfo.Code)
+	assert.Equal(t, 3, errorInfo.Attempts)
+}
+
+func TestRetryPOST(t *testing.T) {
+	ctx := context.Background()
+	srv, requests := newRetryTestServer(t, 1, http.StatusTooManyRequests)
+	client, err := newClient("s3cr3t", []Option{
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	})
+	assert.NoError(t, err)
+
+	// POST requests are not retried by default.
+	err = client.RevokeKey(ctx, "app", "key")
+	assert.Error(t, err)
+	assert.Equal(t, 1, *requests)
+
+	client.retry.RetryPOST = true
+	*requests = 0
+	err = client.RevokeKey(ctx, "app", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, *requests)
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	ctx := context.Background()
+	srv, requests := newRetryTestServer(t, 1, http.StatusBadRequest)
+	client, err := newClient("s3cr3t", []Option{
+		WithURL(srv.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	})
+	assert.NoError(t, err)
+
+	_, err = client.Me(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, *requests)
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d, ok := retryAfter(http.Header{"Retry-After": {"7"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, d)
+
+	d, ok = retryAfter(http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	_, ok = retryAfter(http.Header{"Retry-After": {"soon"}}, now)
+	assert.False(t, ok)
+
+	policy := RetryPolicy{MinBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	for attempt := 1; attempt <= 5; attempt++ {
+		d := policy.delay(attempt, http.Header{})
+		assert.LessOrEqual(t, d, 3*time.Second)
+		assert.Greater(t, d, time.Duration(0))
+	}
+	assert.Equal(t, 3*time.Second, policy.delay(1, http.Header{"Retry-After": {"60"}}))
+}
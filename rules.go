@@ -1,8 +1,11 @@
 package control
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
+	"strings"
 )
 
 type NewRuleNoJson NewRule
@@ -97,9 +100,6 @@ func (r *Rule) UnmarshalJSON(data []byte) error {
 	case "pulsar":
 		var t PulsarTarget
 		err = json.Unmarshal(raw.Target, &t)
-		if err != nil {
-			fmt.Println(err, string(raw.Target))
-		}
 		r.Target = &t
 	case "kafka":
 		var t KafkaTarget
@@ -236,6 +236,46 @@ func (r *NewRule) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&raw)
 }
 
+// UnmarshalJSON decodes a rule in the format it is sent to the Control API,
+// which includes the rule type.
+func (r *NewRule) UnmarshalJSON(data []byte) error {
+	var rule Rule
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	*r = NewRule{Status: rule.Status, RequestMode: rule.RequestMode, Source: rule.Source, Target: rule.Target}
+	return nil
+}
+
+// RuleUpdate is used to update a rule. Only the fields which are not nil
+// are changed.
+type RuleUpdate struct {
+	// The status of the rule. Rules can be enabled or disabled.
+	Status *string `json:"status,omitempty"`
+	// RequestMode. You can read more about the difference between single and batched
+	// events in the Ably documentation. https://ably.com/documentation/general/events#batching
+	RequestMode *RequestMode `json:"requestMode,omitempty"`
+	// The rule source.
+	Source *Source `json:"source,omitempty"`
+	// The rule target, which replaces the existing target.
+	Target Target `json:"target,omitempty"`
+}
+
+type RuleUpdateNoJson RuleUpdate
+
+type rawRuleUpdate struct {
+	RuleType string `json:"ruleType,omitempty"`
+	*RuleUpdateNoJson
+}
+
+func (r *RuleUpdate) MarshalJSON() ([]byte, error) {
+	raw := rawRuleUpdate{RuleUpdateNoJson: (*RuleUpdateNoJson)(r)}
+	if r.Target != nil {
+		raw.RuleType = r.Target.TargetType()
+	}
+	return json.Marshal(&raw)
+}
+
 // PulsarAuthentication is used to authenticate for Pulsar rules
 type PulsarAuthentication struct {
 	// Authentication mode.
@@ -669,35 +709,76 @@ func (s *HttpTarget) TargetType() string {
 }
 
 // Lists the rules for the application specified by the application ID.
-func (c *Client) Rules(appID string) ([]Rule, error) {
+func (c *Client) Rules(ctx context.Context, appID string) ([]Rule, error) {
+	return collect(c.AllRules(ctx, appID))
+}
+
+// AllRules returns an iterator over the rules for the application specified
+// by the application ID, which fetches further pages as it is consumed.
+func (c *Client) AllRules(ctx context.Context, appID string) iter.Seq2[Rule, error] {
+	return list[Rule](ctx, c, "Rules", "/apps/"+appID+"/rules")
+}
+
+// appRules lists both the rules and the ingress rules of the application
+// specified by the application ID, which are returned by the same call and
+// are told apart by their rule type.
+func (c *Client) appRules(ctx context.Context, appID string) ([]Rule, []IngressRule, error) {
 	var rules []Rule
-	err := c.request("GET", "/apps/"+appID+"/rules", nil, &rules)
-	return rules, err
+	var ingressRules []IngressRule
+	for raw, err := range list[json.RawMessage](ctx, c, "Rules", "/apps/"+appID+"/rules") {
+		if err != nil {
+			return nil, nil, err
+		}
+		var typed struct {
+			RuleType string `json:"ruleType"`
+		}
+		if err := json.Unmarshal(raw, &typed); err != nil {
+			return nil, nil, err
+		}
+		if strings.HasPrefix(typed.RuleType, "ingress") {
+			var rule IngressRule
+			if err := json.Unmarshal(raw, &rule); err != nil {
+				return nil, nil, err
+			}
+			ingressRules = append(ingressRules, rule)
+			continue
+		}
+		var rule Rule
+		if err := json.Unmarshal(raw, &rule); err != nil {
+			return nil, nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, ingressRules, nil
 }
 
 // Returns the rule specified by the rule ID, for the application specified by application ID.
-func (c *Client) Rule(appID, ruleID string) (Rule, error) {
+func (c *Client) Rule(ctx context.Context, appID, ruleID string) (Rule, error) {
 	var rule Rule
-	err := c.request("GET", "/apps/"+appID+"/rules/"+ruleID, nil, &rule)
+	err := c.request(ctx, "Rule", "GET", "/apps/"+appID+"/rules/"+ruleID, nil, &rule)
 	return rule, err
 }
 
 // Creates a rule for the application with the specified application ID.
-func (c *Client) CreateRule(appID string, rule *NewRule) (Rule, error) {
+//
+// Rules have no name, so an earlier attempt can't be looked up, and the call
+// is only retried if the retry policy's RetryPOST is set. The request still
+// carries an idempotency key.
+func (c *Client) CreateRule(ctx context.Context, appID string, rule *NewRule) (Rule, error) {
 	var out Rule
-	err := c.request("POST", "/apps/"+appID+"/rules", &rule, &out)
+	err := c.create(ctx, "CreateRule", "/apps/"+appID+"/rules", rule, &out, nil)
 	return out, err
 }
 
 // Updates the rule specified by the rule ID, for the application specified by application ID.
-func (c *Client) UpdateRule(appID, ruleID string, rule *NewRule) (Rule, error) {
+func (c *Client) UpdateRule(ctx context.Context, appID, ruleID string, rule *RuleUpdate) (Rule, error) {
 	var out Rule
-	err := c.request("PATCH", "/apps/"+appID+"/rules/"+ruleID, &rule, &out)
+	err := c.request(ctx, "UpdateRule", "PATCH", "/apps/"+appID+"/rules/"+ruleID, rule, &out)
 	return out, err
 }
 
 // Deletes the rule specified by the rule ID, for the application specified by application ID.
-func (c *Client) DeleteRule(appID, ruleID string) error {
-	err := c.request("DELETE", "/apps/"+appID+"/rules/"+ruleID, nil, nil)
+func (c *Client) DeleteRule(ctx context.Context, appID, ruleID string) error {
+	err := c.request(ctx, "DeleteRule", "DELETE", "/apps/"+appID+"/rules/"+ruleID, nil, nil)
 	return err
 }
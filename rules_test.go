@@ -1,6 +1,8 @@
 package control
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -41,6 +43,48 @@ func TestRuleKafka(t *testing.T) {
 	testRule(t, target)
 }
 
+// TestRuleKafkaReplay makes the calls TestRuleKafka makes against a live
+// account, answered from a recording of them.
+func TestRuleKafkaReplay(t *testing.T) {
+	ctx := context.Background()
+	client := newCassetteClient(t, "rule_kafka")
+
+	app, err := client.CreateApp(ctx, &NewApp{Name: "cassette-rule-kafka", Status: "enabled"})
+	assert.NoError(t, err)
+	rule := NewRule{
+		Status:      "enabled",
+		RequestMode: Single,
+		Source:      Source{ChannelFilter: "aaa", Type: ChannelMessage},
+		Target: &KafkaTarget{
+			RoutingKey: "1234",
+			Brokers:    []string{"a", "b", "c"},
+			Authentication: KafkaAuthentication{
+				Sasl: Sasl{Mechanism: Plain, Username: "b", Password: "c"},
+			},
+			Format: Json,
+		},
+	}
+	r, err := client.CreateRule(ctx, app.ID, &rule)
+	assert.NoError(t, err)
+	assert.Equal(t, rule.Source, r.Source)
+	target, ok := r.Target.(*KafkaTarget)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b", "c"}, target.Brokers)
+	assert.Equal(t, "b", target.Authentication.Sasl.Username)
+	assert.NotEmpty(t, r.ID)
+
+	r2, err := client.Rule(ctx, app.ID, r.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, r, r2)
+
+	r, err = client.UpdateRule(ctx, app.ID, r.ID, &RuleUpdate{Status: String("disabled")})
+	assert.NoError(t, err)
+	assert.Equal(t, "disabled", r.Status)
+
+	assert.NoError(t, client.DeleteRule(ctx, app.ID, r.ID))
+	assert.NoError(t, client.DeleteApp(ctx, app.ID))
+}
+
 func TestRuleAmqpExternal(t *testing.T) {
 	target := &AmqpExternalTarget{
 		Url:                "amqps://test.com",
@@ -181,6 +225,7 @@ func TestRuleHttp(t *testing.T) {
 }
 
 func testRule(t *testing.T, target Target) {
+	ctx := context.Background()
 	client, _ := newTestClient(t)
 	app := newTestApp(t, &client)
 
@@ -196,10 +241,10 @@ func testRule(t *testing.T, target Target) {
 		Capability: map[string][]string{"foo": {"publish"}},
 	}
 
-	q, err := client.CreateQueue(app.ID, &queue)
+	q, err := client.CreateQueue(ctx, app.ID, &queue)
 	assert.NoError(t, err)
 
-	k, err := client.CreateKey(app.ID, &key)
+	k, err := client.CreateKey(ctx, app.ID, &key)
 	assert.NoError(t, err)
 
 	switch t := target.(type) {
@@ -227,7 +272,7 @@ func testRule(t *testing.T, target Target) {
 		Target: target,
 	}
 
-	r, err := client.CreateRule(app.ID, &rule)
+	r, err := client.CreateRule(ctx, app.ID, &rule)
 	assert.NoError(t, err)
 	assert.Equal(t, rule.RequestMode, r.RequestMode)
 	assert.Equal(t, rule.Source, r.Source)
@@ -240,13 +285,42 @@ func testRule(t *testing.T, target Target) {
 	assert.NotEmpty(t, r.Created)
 	assert.NotEmpty(t, r.Modified)
 
-	r2, err := client.Rule(app.ID, r.ID)
+	r2, err := client.Rule(ctx, app.ID, r.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, r, r2)
 
-	err = client.DeleteRule(app.ID, r.ID)
+	r, err = client.UpdateRule(ctx, app.ID, r.ID, &RuleUpdate{Status: String("disabled")})
+	assert.NoError(t, err)
+	assert.Equal(t, "disabled", r.Status)
+	assert.Equal(t, rule.Source, r.Source)
+	assert.Equal(t, rule.Target, r.Target)
+
+	err = client.DeleteRule(ctx, app.ID, r.ID)
 	assert.NoError(t, err)
 
-	err = client.DeleteApp(app.ID)
+	err = client.DeleteApp(ctx, app.ID)
 	assert.NoError(t, err)
 }
+
+func TestRuleUpdateMarshal(t *testing.T) {
+	b, err := json.Marshal(&RuleUpdate{Status: String("disabled")})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":"disabled"}`, string(b))
+
+	b, err = json.Marshal(&RuleUpdate{Target: &HttpTarget{Url: "https://example.com"}})
+	assert.NoError(t, err)
+	var raw map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &raw))
+	assert.Equal(t, "http", raw["ruleType"])
+	assert.Equal(t, "https://example.com", raw["target"].(map[string]interface{})["url"])
+	assert.NotContains(t, raw, "status")
+	assert.NotContains(t, raw, "source")
+}
+
+// TestRuleUnmarshalInvalidPulsar tests that decoding a Pulsar rule doesn't write to
+// stdout when the target is invalid.
+func TestRuleUnmarshalInvalidPulsar(t *testing.T) {
+	var rule Rule
+	err := json.Unmarshal([]byte(`{"ruleType":"pulsar","target":{"topic":1}}`), &rule)
+	assert.Error(t, err)
+}
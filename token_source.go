@@ -0,0 +1,121 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the access token used to authenticate with the
+// Control API. The Client asks for a token before every request, so a
+// TokenSource can rotate tokens without the Client being recreated.
+//
+// A TokenSource must be safe for concurrent use.
+type TokenSource interface {
+	// Token returns the access token to use for the next request.
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenRefresher is implemented by a TokenSource which can fetch a new
+// token when the Control API rejects the current one with a 401 response.
+// The request is then repeated once with the new token.
+type TokenRefresher interface {
+	TokenSource
+	// Refresh discards any cached token and returns a new one.
+	Refresh(ctx context.Context) (string, error)
+}
+
+// WithTokenSource sets the TokenSource used to authenticate requests. It
+// replaces the token passed to NewClient.
+func WithTokenSource(source TokenSource) Option {
+	return func(o *options) {
+		o.tokenSource = source
+	}
+}
+
+// StaticTokenSource returns a TokenSource which always returns token.
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource(token)
+}
+
+type staticTokenSource string
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// EnvTokenSource returns a TokenSource which reads the token from the
+// environment variable with the given name before every request.
+func EnvTokenSource(name string) TokenRefresher {
+	return envTokenSource(name)
+}
+
+type envTokenSource string
+
+func (s envTokenSource) Token(ctx context.Context) (string, error) {
+	token := os.Getenv(string(s))
+	if token == "" {
+		return "", fmt.Errorf("control: environment variable %s is not set", string(s))
+	}
+	return token, nil
+}
+
+func (s envTokenSource) Refresh(ctx context.Context) (string, error) {
+	return s.Token(ctx)
+}
+
+// FileTokenSource returns a TokenSource which reads the token from the file
+// at path, such as one written by a secret manager. The file is read again
+// whenever its modification time or size changes. Leading and trailing
+// white space is ignored.
+func FileTokenSource(path string) TokenRefresher {
+	return &fileTokenSource{path: path}
+}
+
+type fileTokenSource struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+func (s *fileTokenSource) Token(ctx context.Context) (string, error) {
+	return s.read(false)
+}
+
+func (s *fileTokenSource) Refresh(ctx context.Context) (string, error) {
+	return s.read(true)
+}
+
+// read returns the token from the file, reading it again if it changed or
+// force is set.
+func (s *fileTokenSource) read(force bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", fmt.Errorf("control: reading token: %w", err)
+	}
+	if !force && s.token != "" && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.token, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("control: reading token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("control: token file " + s.path + " is empty")
+	}
+	s.token = token
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return token, nil
+}
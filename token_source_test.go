@@ -0,0 +1,91 @@
+package control
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileTokenSource(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(path, []byte("first\n"), 0600))
+
+	source := FileTokenSource(path)
+	token, err := source.Token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", token)
+
+	assert.NoError(t, os.WriteFile(path, []byte("second-token\n"), 0600))
+	token, err = source.Token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "second-token", token)
+
+	assert.NoError(t, os.Remove(path))
+	_, err = source.Token(ctx)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestEnvTokenSource(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TEST_CONTROL_TOKEN", "")
+
+	source := EnvTokenSource("TEST_CONTROL_TOKEN")
+	_, err := source.Token(ctx)
+	assert.Error(t, err)
+
+	t.Setenv("TEST_CONTROL_TOKEN", "s3cr3t")
+	token, err := source.Token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", token)
+}
+
+// TestTokenSourceRefresh tests that a request rejected with a 401 response
+// is repeated once with a refreshed token.
+func TestTokenSourceRefresh(t *testing.T) {
+	ctx := context.Background()
+	var tokens []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		tokens = append(tokens, auth)
+		w.Header().Set("Content-Type", "application/json")
+		if auth != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Access denied","code":40100,"statusCode":401}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0600))
+
+	client, err := NewClientWithAccountID("", "acc", WithURL(srv.URL), WithTokenSource(FileTokenSource(path)))
+	assert.NoError(t, err)
+
+	// the token hasn't changed, so the request is not repeated.
+	_, err = client.Me(ctx)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Equal(t, []string{"Bearer old"}, tokens)
+
+	// the token is rotated, but the file's modification time and size are
+	// unchanged so the cached token is used until it is rejected.
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte("new"), 0600))
+	assert.NoError(t, os.Chtimes(path, time.Time{}, info.ModTime()))
+
+	tokens = nil
+	_, err = client.Me(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "Bearer new", tokens[1])
+}
@@ -3,4 +3,4 @@ package control
 // VERSION is the version of this package.
 //
 // It is sent in requests to the Control API in the Ably-Agent HTTP header.
-const VERSION = "0.6.0"
+const VERSION = "0.7.0"